@@ -66,12 +66,47 @@ func InitDB() {
 
 	dns := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		user, password, host, port, dbname)
-	database, err := gorm.Open(mysql.Open(dns), &gorm.Config{})
+	// TranslateError：唯一索引冲突等错误转换为 gorm.ErrDuplicatedKey，便于业务层识别
+	database, err := gorm.Open(mysql.Open(dns), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic("连接数据库失败")
 	}
 	// 自动迁移模式，自动创建数据库
-	database.AutoMigrate(&models.User{}, &models.LedgerEntry{})
+	database.AutoMigrate(
+		&models.User{},
+		&models.LedgerEntry{},
+		&models.AuditLog{},
+		&models.Ledger{},
+		&models.LedgerMember{},
+		&models.LedgerInvitation{},
+		&models.SharedExpense{},
+		&models.SharedExpenseShare{},
+		&models.Settlement{},
+		&models.Loan{},
+		&models.LoanRepayment{},
+		&models.ExchangeRate{},
+		&models.Tag{},
+		&models.Attachment{},
+		&models.EntrySplit{},
+		&models.IdempotencyKey{},
+		&models.Account{},
+		&models.JournalTransaction{},
+		&models.Posting{},
+		&models.Reconciliation{},
+		&models.Goal{},
+		&models.NetWorthSnapshot{},
+		&models.NetWorthItem{},
+	)
+	if err := migrateClientIDIndex(database); err != nil {
+		panic("迁移 client_id 索引失败: " + err.Error())
+	}
+	if err := migrateDefaultLedgers(database); err != nil {
+		panic("迁移默认账本失败: " + err.Error())
+	}
+	if err := migrateDefaultAccounts(database); err != nil {
+		panic("迁移默认科目失败: " + err.Error())
+	}
+	FullTextSearch = migrateFullTextIndex(database)
 
 	DB = database
 	fmt.Println("数据库连接成功")
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"go-ledger/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrateDefaultLedgers 为还没有账本的用户创建默认账本，并把尚未归属账本的账单迁移进去
+// 只处理 current_ledger_id = 0 的用户和 ledger_id = 0 的账单，重复执行不会产生副作用
+func migrateDefaultLedgers(db *gorm.DB) error {
+	var users []models.User
+	if err := db.Where("current_ledger_id = 0").Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			ledger := models.Ledger{Name: models.DefaultLedgerName, OwnerID: user.ID}
+			if err := tx.Create(&ledger).Error; err != nil {
+				return err
+			}
+			member := models.LedgerMember{LedgerID: ledger.ID, UserID: user.ID, Role: models.LedgerRoleOwner}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.LedgerEntry{}).Unscoped().
+				Where("user_id = ? AND ledger_id = 0", user.ID).
+				Update("ledger_id", ledger.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&user).Update("current_ledger_id", ledger.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDefaultAccounts 为还没有任何科目的账本创建默认科目
+// 新账本在创建时已生成默认科目，这里补齐旧版本创建、尚未使用过复式记账的账本；重复执行不会产生副作用
+func migrateDefaultAccounts(db *gorm.DB) error {
+	var ledgerIDs []uint
+	err := db.Model(&models.Ledger{}).
+		Where("NOT EXISTS (SELECT 1 FROM accounts WHERE accounts.ledger_id = ledgers.id)").
+		Pluck("id", &ledgerIDs).Error
+	if err != nil {
+		return err
+	}
+	for _, ledgerID := range ledgerIDs {
+		accounts := models.DefaultAccounts(ledgerID)
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&accounts).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacyClientIDIndex 旧版本在 client_id 上建立的全局唯一索引，现已改为 (ledger_id, client_id) 联合唯一索引
+const legacyClientIDIndex = "idx_ledger_entries_client_id"
+
+// migrateClientIDIndex 删除旧的 client_id 全局唯一索引，新的联合唯一索引由 AutoMigrate 创建
+func migrateClientIDIndex(db *gorm.DB) error {
+	if !db.Migrator().HasIndex(&models.LedgerEntry{}, legacyClientIDIndex) {
+		return nil
+	}
+	return db.Migrator().DropIndex(&models.LedgerEntry{}, legacyClientIDIndex)
+}
+
+// entryFullTextIndex 账单备注和分类上的全文索引名
+const entryFullTextIndex = "idx_entry_fulltext"
+
+// FullTextSearch 为 true 表示数据库支持全文索引，账单搜索使用 MATCH ... AGAINST
+// 否则（非 MySQL，或 MySQL 不支持 ngram 解析器）搜索退化为 LIKE
+var FullTextSearch bool
+
+// migrateFullTextIndex 在 MySQL 上为账单的 remark、category 创建 ngram 全文索引，以支持中文分词
+// 创建失败不影响启动，只是搜索退化为 LIKE
+func migrateFullTextIndex(db *gorm.DB) bool {
+	if db.Dialector.Name() != "mysql" {
+		return false
+	}
+	if db.Migrator().HasIndex(&models.LedgerEntry{}, entryFullTextIndex) {
+		return true
+	}
+	err := db.Exec("CREATE FULLTEXT INDEX " + entryFullTextIndex + " ON ledger_entries (remark, category) WITH PARSER ngram").Error
+	if err != nil {
+		fmt.Printf("Warning: 创建全文索引失败，账单搜索将使用 LIKE: %v\n", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"go-ledger/services"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var attachmentService = new(services.AttachmentService)
+
+// UploadAttachment - 为账单上传附件（表单字段 file）
+func UploadAttachment(c *gin.Context) {
+	entryID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	// 限制请求体大小，额外留出 1MB 给表单的其他部分
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.AttachmentMaxSize()+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传文件，文件不能超过大小限制"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return
+	}
+	defer file.Close()
+	userID, _ := c.Get("userID")
+
+	attachment, err := attachmentService.Upload(entryID, userID.(uint), header.Filename, header.Size, file)
+	if err != nil {
+		respondError(c, err, "上传失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": attachment})
+}
+
+// ListAttachments - 查询账单的附件
+func ListAttachments(c *gin.Context) {
+	entryID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	attachments, err := attachmentService.List(entryID, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": attachments})
+}
+
+// DownloadAttachment - 下载附件
+func DownloadAttachment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	attachment, body, err := attachmentService.Open(id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "下载失败")
+		return
+	}
+	defer body.Close()
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	c.Header("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(attachment.FileName))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, body); err != nil {
+		log.Printf("附件 %d 下载中断: %v", attachment.ID, err)
+	}
+}
+
+// DeleteAttachment - 删除附件
+func DeleteAttachment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := attachmentService.Delete(id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var auditService = new(services.AuditService)
+
+// GetEntryHistory - 查询账单的变更历史（新增、修改、删除、恢复、彻底删除），包含变更前后的快照
+func GetEntryHistory(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+	page, pageSize := utils.GetPageParams(c)
+
+	logs, total, err := auditService.EntryHistory(id, userID.(uint), page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	respondAuditLogs(c, logs, total, page, pageSize)
+}
+
+// ListActivity - 查询当前用户的操作记录，可按 action 筛选
+func ListActivity(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	page, pageSize := utils.GetPageParams(c)
+
+	logs, total, err := auditService.ListActivity(userID.(uint), c.Query("action"), page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	respondAuditLogs(c, logs, total, page, pageSize)
+}
+
+func respondAuditLogs(c *gin.Context, logs []models.AuditLog, total int64, page, pageSize int) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": logs,
+		"meta": gin.H{
+			"current_page": page,
+			"page_size":    pageSize,
+			"total":        total,
+			"total_pages":  (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"go-ledger/services"
+	"go-ledger/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,6 +30,11 @@ func Register(c *gin.Context) {
 	// 2. 调用 Service 注册
 	user, err := authService.Register(input.Username, input.Password)
 	if err != nil {
+		var fields utils.FieldErrors
+		if errors.As(err, &fields) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数校验失败", "fields": fields})
+			return
+		}
 		// 这里简单处理，具体错误码可以根据 err 类型细分
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -45,8 +52,12 @@ func Login(c *gin.Context) {
 	}
 
 	// 1. 调用 Service 登录
-	token, err := authService.Login(input.Username, input.Password)
+	token, err := authService.Login(c.Request.Context(), input.Username, input.Password, c.ClientIP())
 	if err != nil {
+		if errors.Is(err, services.ErrLoginLocked) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountInput 新增、修改科目的输入参数
+type AccountInput struct {
+	LedgerID uint   `json:"ledger_id"` // 所属账本，不传则使用当前账本，修改时忽略
+	Code     string `json:"code" binding:"required"`
+	Name     string `json:"name" binding:"required"`
+	Type     string `json:"type" binding:"required"` // asset、liability、equity、income、expense
+	Remark   string `json:"remark"`
+}
+
+// JournalInput 录入记账凭证的输入参数
+type JournalInput struct {
+	LedgerID uint           `json:"ledger_id"` // 所属账本，不传则使用当前账本
+	Date     time.Time      `json:"date" binding:"required"`
+	Currency string         `json:"currency"` // 不传则使用本位币
+	Memo     string         `json:"memo"`
+	Postings []PostingInput `json:"postings" binding:"required,dive"`
+}
+
+// PostingInput 凭证的一条分录，debit、credit 只能填一个
+type PostingInput struct {
+	AccountID uint    `json:"account_id" binding:"required"`
+	Debit     float64 `json:"debit"`
+	Credit    float64 `json:"credit"`
+	Memo      string  `json:"memo"`
+}
+
+var bookService = new(services.BookService)
+
+// ListAccounts - 查询账本的科目表，首次查询时自动创建默认科目
+func ListAccounts(c *gin.Context) {
+	ledgerID, err := strconv.ParseUint(c.DefaultQuery("ledger_id", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	accounts, err := bookService.ListAccounts(userID.(uint), uint(ledgerID))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": accounts})
+}
+
+// CreateAccount - 新增科目
+func CreateAccount(c *gin.Context) {
+	var input AccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	account := models.Account{
+		LedgerID: input.LedgerID,
+		Code:     input.Code,
+		Name:     input.Name,
+		Type:     input.Type,
+		Remark:   input.Remark,
+	}
+	if err := bookService.CreateAccount(userID.(uint), &account); err != nil {
+		respondError(c, err, "创建失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": account})
+}
+
+// UpdateAccount - 修改科目
+func UpdateAccount(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input AccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	account, err := bookService.UpdateAccount(id, userID.(uint), &models.Account{
+		Code:   input.Code,
+		Name:   input.Name,
+		Type:   input.Type,
+		Remark: input.Remark,
+	})
+	if err != nil {
+		respondError(c, err, "修改失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": account})
+}
+
+// DeleteAccount - 删除未被凭证使用的科目
+func DeleteAccount(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := bookService.DeleteAccount(id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
+
+// CreateJournal - 录入记账凭证
+func CreateJournal(c *gin.Context) {
+	var input JournalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	txn := models.JournalTransaction{
+		LedgerID: input.LedgerID,
+		Date:     input.Date,
+		Currency: input.Currency,
+		Memo:     input.Memo,
+		Postings: make([]models.Posting, 0, len(input.Postings)),
+	}
+	for _, p := range input.Postings {
+		txn.Postings = append(txn.Postings, models.Posting{
+			AccountID: p.AccountID,
+			Debit:     p.Debit,
+			Credit:    p.Credit,
+			Memo:      p.Memo,
+		})
+	}
+	if err := bookService.CreateJournal(userID.(uint), &txn); err != nil {
+		respondError(c, err, "创建失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": txn})
+}
+
+// ListJournal - 分页查询手工录入的凭证
+func ListJournal(c *gin.Context) {
+	var filter models.ReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+	page, pageSize := utils.GetPageParams(c)
+
+	transactions, total, err := bookService.ListJournal(userID.(uint), filter, page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": transactions,
+		"meta": gin.H{
+			"current_page": page,
+			"page_size":    pageSize,
+			"total":        total,
+			"total_pages":  (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// DeleteJournal - 删除手工录入的凭证
+func DeleteJournal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := bookService.DeleteJournal(id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
+
+// GetEntryPostings - 查询账单对应的复式记账分录
+func GetEntryPostings(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	postings, err := bookService.EntryPostings(id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": postings})
+}
+
+// GetTrialBalance - 试算平衡表
+func GetTrialBalance(c *gin.Context) {
+	var filter models.ReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	report, err := bookService.TrialBalance(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// GetBalanceSheet - 资产负债表
+func GetBalanceSheet(c *gin.Context) {
+	var filter models.ReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	report, err := bookService.BalanceSheet(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// GetIncomeStatement - 利润表
+func GetIncomeStatement(c *gin.Context) {
+	var filter models.ReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	report, err := bookService.IncomeStatement(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetBaseCurrencyInput 修改本位币的输入参数
+type SetBaseCurrencyInput struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+// SaveExchangeRateInput 录入汇率的输入参数：1 单位 from_currency = rate 单位 to_currency
+type SaveExchangeRateInput struct {
+	FromCurrency string    `json:"from_currency" binding:"required"`
+	ToCurrency   string    `json:"to_currency" binding:"required"`
+	Date         time.Time `json:"date" binding:"required"`
+	Rate         float64   `json:"rate" binding:"required"`
+}
+
+// 汇率导入文件大小上限
+const rateImportMaxBytes = 2 << 20
+
+var currencyService = new(services.CurrencyService)
+
+// SetBaseCurrency - 修改本位币
+func SetBaseCurrency(c *gin.Context) {
+	var input SetBaseCurrencyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	currency, err := currencyService.SetBaseCurrency(userID.(uint), input.Currency)
+	if err != nil {
+		respondError(c, err, "修改失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"base_currency": currency}})
+}
+
+// SaveExchangeRate - 录入汇率，同一天同一币种对已存在时覆盖
+func SaveExchangeRate(c *gin.Context) {
+	var input SaveExchangeRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	rate := models.ExchangeRate{
+		FromCurrency: input.FromCurrency,
+		ToCurrency:   input.ToCurrency,
+		Date:         input.Date,
+		Rate:         input.Rate,
+	}
+	if err := currencyService.SaveRate(userID.(uint), &rate); err != nil {
+		respondError(c, err, "保存失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "保存成功"})
+}
+
+// ListExchangeRates - 查询汇率
+func ListExchangeRates(c *gin.Context) {
+	var filter models.ExchangeRateFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	rates, err := currencyService.ListRates(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rates})
+}
+
+// DeleteExchangeRate - 删除汇率
+func DeleteExchangeRate(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := currencyService.DeleteRate(id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
+
+// ImportExchangeRates - 通过 CSV 文件批量导入汇率（表单字段 file）
+func ImportExchangeRates(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请上传汇率文件"})
+		return
+	}
+	if header.Size > rateImportMaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件不能超过 2MB"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return
+	}
+	defer file.Close()
+	userID, _ := c.Get("userID")
+
+	count, err := currencyService.ImportRates(userID.(uint), file)
+	if err != nil {
+		respondError(c, err, "导入失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"imported": count}})
+}
@@ -5,6 +5,7 @@ import (
 	"go-ledger/services"
 	"go-ledger/utils"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,11 +13,36 @@ import (
 
 // CreateEntryInput 定义创建账单的输入参数
 type CreateEntryInput struct {
-	Type     int       `json:"type" binding:"required"`
-	Amount   float64   `json:"amount" binding:"required"`
-	Category string    `json:"category" binding:"required"`
-	Date     time.Time `json:"date" binding:"required"`
-	Remark   string    `json:"remark"`
+	LedgerID uint              `json:"ledger_id"` // 所属账本，不传则使用当前账本
+	Type     int               `json:"type" binding:"required"`
+	Amount   float64           `json:"amount" binding:"required"`
+	Currency string            `json:"currency"` // 币种代码，不传则使用本位币
+	Category string            `json:"category" binding:"required"`
+	Account  string            `json:"account" binding:"max=50"` // 资金账户，可选，如 "现金"、"招行信用卡"
+	Date     time.Time         `json:"date" binding:"required"`
+	Remark   string            `json:"remark"`
+	Tags     []string          `json:"tags"`                            // 标签名称，账本中不存在的会自动创建
+	Splits   []EntrySplitInput `json:"splits" binding:"omitempty,dive"` // 拆分明细，可选，各行金额之和必须等于 amount
+}
+
+// EntrySplitInput 账单拆分明细的一行
+type EntrySplitInput struct {
+	Category string  `json:"category" binding:"required"`
+	Amount   float64 `json:"amount" binding:"required"`
+	Remark   string  `json:"remark"`
+}
+
+// UpdateEntryInput 定义修改账单的输入参数，整体替换，tags 不传表示清空标签
+type UpdateEntryInput struct {
+	Type     int               `json:"type" binding:"required"`
+	Amount   float64           `json:"amount" binding:"required"`
+	Currency string            `json:"currency"` // 不传则保持原币种
+	Category string            `json:"category" binding:"required"`
+	Account  string            `json:"account" binding:"max=50"`
+	Date     time.Time         `json:"date" binding:"required"`
+	Remark   string            `json:"remark"`
+	Tags     []string          `json:"tags"`
+	Splits   []EntrySplitInput `json:"splits" binding:"omitempty,dive"` // 不传表示取消拆分
 }
 
 var entryService = new(services.EntryService)
@@ -38,16 +64,21 @@ func CreateEntry(c *gin.Context) {
 	// 手动构造模型，强制使用 Context 中的 userID
 	entry := models.LedgerEntry{
 		UserID:   userID.(uint), // 类型断言
+		LedgerID: input.LedgerID,
 		Type:     input.Type,
 		Amount:   input.Amount,
+		Currency: input.Currency,
 		Category: input.Category,
+		Account:  input.Account,
 		Date:     input.Date,
 		Remark:   input.Remark,
+		Tags:     tagsFromNames(input.Tags),
+		Splits:   splitsFromInput(input.Splits),
 	}
 
 	// 调用 Service
-	if err := entryService.CreateEntry(&entry); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建失败: " + err.Error()})
+	if err := entryService.CreateEntry(c.Request.Context(), &entry); err != nil {
+		respondError(c, err, "创建失败")
 		return
 	}
 
@@ -56,7 +87,8 @@ func CreateEntry(c *gin.Context) {
 
 // CreateEntryByAIInput 定义 AI 记账的输入参数
 type CreateEntryByAIInput struct {
-	Text string `json:"text" binding:"required"`
+	LedgerID uint   `json:"ledger_id"` // 所属账本，不传则使用当前账本
+	Text     string `json:"text" binding:"required"`
 }
 
 // CreateEntryByAI - 智能记账接口
@@ -80,12 +112,13 @@ func CreateEntryByAI(c *gin.Context) {
 		return
 	}
 
-	// 2. 补全 UserID
+	// 2. 补全 UserID 和账本
 	entry.UserID = userID.(uint)
+	entry.LedgerID = input.LedgerID
 
 	// 3. 保存到数据库 (复用 EntryService)
-	if err := entryService.CreateEntry(entry); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存失败: " + err.Error()})
+	if err := entryService.CreateEntry(c.Request.Context(), entry); err != nil {
+		respondError(c, err, "保存失败")
 		return
 	}
 
@@ -96,6 +129,7 @@ func CreateEntryByAI(c *gin.Context) {
 }
 
 // FindEntries - 获取所有账单
+// 默认按 page/page_size 分页；带上 cursor 参数（第一页传空值）时改用游标分页
 func FindEntries(c *gin.Context) {
 	userID, _ := c.Get("userID") // 中间件保证了这里一定有值
 	// 1. 绑定筛选参数
@@ -105,19 +139,75 @@ func FindEntries(c *gin.Context) {
 		return
 	}
 
-	// 2. 获取分页参数
+	// 2. 获取分页参数；页码分页默认统计总数，游标分页默认不统计
 	page, pageSize := utils.GetPageParams(c)
+	cursor, cursorMode := c.GetQuery("cursor")
+	withTotal := !cursorMode
+	if value := c.Query("with_total"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondError(c, utils.FieldErrors{"with_total": "只能是 true 或 false"}, "参数错误")
+			return
+		}
+		withTotal = parsed
+	}
 
-	// 3. 调用 Service 查询
-	entries, total, err := entryService.FindEntries(userID.(uint), filter, page, pageSize)
+	// 3. 调用 Service 查询并返回结果
+	if cursorMode {
+		entries, nextCursor, total, err := entryService.FindEntriesByCursor(userID.(uint), filter, cursor, pageSize, withTotal)
+		if err != nil {
+			respondError(c, err, "查询失败")
+			return
+		}
+		meta := gin.H{
+			"page_size":   pageSize,
+			"next_cursor": nil,
+			"has_more":    nextCursor != "",
+		}
+		if nextCursor != "" {
+			meta["next_cursor"] = nextCursor
+		}
+		if withTotal {
+			meta["total"] = total
+		}
+		respondWithETag(c, gin.H{"data": entries, "meta": meta})
+		return
+	}
+
+	entries, total, err := entryService.FindEntries(userID.(uint), filter, page, pageSize, withTotal)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失败"})
+		respondError(c, err, "查询失败")
 		return
 	}
+	meta := gin.H{
+		"current_page": page,
+		"page_size":    pageSize,
+	}
+	if withTotal {
+		meta["total"] = total
+		meta["total_pages"] = (total + int64(pageSize) - 1) / int64(pageSize)
+	}
+	respondWithETag(c, gin.H{"data": entries, "meta": meta})
+}
 
-	// 4. 返回结果
-	c.JSON(http.StatusOK, gin.H{
-		"data": entries,
+// SearchEntries - 全文搜索账单备注和分类，按相关度排序并返回高亮片段
+// 筛选参数同账单列表，排序参数不生效
+func SearchEntries(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	var filter models.EntryFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	page, pageSize := utils.GetPageParams(c)
+
+	results, total, err := entryService.SearchEntries(userID.(uint), c.Query("q"), filter, page, pageSize)
+	if err != nil {
+		respondError(c, err, "搜索失败")
+		return
+	}
+	respondWithETag(c, gin.H{
+		"data": results,
 		"meta": gin.H{
 			"current_page": page,
 			"page_size":    pageSize,
@@ -127,13 +217,93 @@ func FindEntries(c *gin.Context) {
 	})
 }
 
+// GetEntry - 查询单条账单，响应头 ETag 为账单版本，修改、删除时通过 If-Match 传回
+func GetEntry(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	entry, err := entryService.GetEntry(id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	if notModified(c, entryETag(entry)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+// UpdateEntry - 修改账单
+func UpdateEntry(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+	var input UpdateEntryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	entry, err := entryService.UpdateEntry(c.Request.Context(), id, userID.(uint), version, &models.LedgerEntry{
+		Type:     input.Type,
+		Amount:   input.Amount,
+		Currency: input.Currency,
+		Category: input.Category,
+		Account:  input.Account,
+		Date:     input.Date,
+		Remark:   input.Remark,
+		Tags:     tagsFromNames(input.Tags),
+		Splits:   splitsFromInput(input.Splits),
+	})
+	if err != nil {
+		respondError(c, err, "修改失败")
+		return
+	}
+	c.Header("ETag", entryETag(entry))
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+// tagsFromNames 把输入的标签名称转换为只带名称的标签，由 Service 负责查找或创建
+func tagsFromNames(names []string) []models.Tag {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, models.Tag{Name: name})
+	}
+	return tags
+}
+
+// splitsFromInput 把输入的拆分明细转换为模型
+func splitsFromInput(input []EntrySplitInput) []models.EntrySplit {
+	splits := make([]models.EntrySplit, 0, len(input))
+	for _, s := range input {
+		splits = append(splits, models.EntrySplit{Category: s.Category, Amount: s.Amount, Remark: s.Remark})
+	}
+	return splits
+}
+
 // DeleteEntry - 删除账单
 func DeleteEntry(c *gin.Context) {
-	id := c.Param("id")
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
 	userID, _ := c.Get("userID")
 
-	if err := entryService.DeleteEntry(id, userID.(uint)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+	if err := entryService.DeleteEntry(c.Request.Context(), id, userID.(uint), version); err != nil {
+		respondError(c, err, "删除失败")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
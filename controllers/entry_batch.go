@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 批量操作的事务模式
+const (
+	BatchModeAtomic  = "atomic"  // 全部成功或全部回滚（默认）
+	BatchModePartial = "partial" // 逐项执行，返回每一项的结果
+)
+
+// BatchEntriesInput 批量新增、修改账单的输入参数
+// items 按原始 JSON 接收，逐项解析校验，部分模式下单项格式错误不影响其他项
+type BatchEntriesInput struct {
+	Mode  string            `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	Items []json.RawMessage `json:"items" binding:"required,min=1,max=100"`
+}
+
+// BatchUpdateItemInput 批量修改中的一项
+// version 相当于单条接口的 If-Match，为客户端看到的账单 sync_seq，与当前版本不一致时该项返回 412
+type BatchUpdateItemInput struct {
+	ID      uint    `json:"id" binding:"required"`
+	Version *uint64 `json:"version" binding:"required"`
+	UpdateEntryInput
+}
+
+// BatchDeleteInput 批量删除账单的输入参数
+type BatchDeleteInput struct {
+	Mode  string                 `json:"mode" binding:"omitempty,oneof=atomic partial"`
+	Items []BatchDeleteItemInput `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// BatchDeleteItemInput 批量删除中的一项，version 同 BatchUpdateItemInput
+type BatchDeleteItemInput struct {
+	ID      uint    `json:"id" binding:"required"`
+	Version *uint64 `json:"version" binding:"required"`
+}
+
+// BatchCreateEntries - 批量新增账单
+func BatchCreateEntries(c *gin.Context) {
+	var input BatchEntriesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	items := make([]services.BatchItem, 0, len(input.Items))
+	for _, raw := range input.Items {
+		var item CreateEntryInput
+		if err := decodeBatchItem(raw, &item); err != nil {
+			items = append(items, services.BatchItem{Err: err})
+			continue
+		}
+		items = append(items, services.BatchItem{Entry: &models.LedgerEntry{
+			UserID:   userID.(uint),
+			LedgerID: item.LedgerID,
+			Type:     item.Type,
+			Amount:   item.Amount,
+			Currency: item.Currency,
+			Category: item.Category,
+			Account:  item.Account,
+			Date:     item.Date,
+			Remark:   item.Remark,
+			Tags:     tagsFromNames(item.Tags),
+			Splits:   splitsFromInput(item.Splits),
+		}})
+	}
+
+	results, err := entryService.BatchCreateEntries(c.Request.Context(), items, input.Mode != BatchModePartial)
+	respondBatch(c, results, err, "批量创建失败")
+}
+
+// BatchUpdateEntries - 批量修改账单
+func BatchUpdateEntries(c *gin.Context) {
+	var input BatchEntriesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	items := make([]services.BatchItem, 0, len(input.Items))
+	for _, raw := range input.Items {
+		var item BatchUpdateItemInput
+		if err := decodeBatchItem(raw, &item); err != nil {
+			items = append(items, services.BatchItem{Err: err})
+			continue
+		}
+		items = append(items, services.BatchItem{ID: item.ID, Version: item.Version, Entry: &models.LedgerEntry{
+			Type:     item.Type,
+			Amount:   item.Amount,
+			Currency: item.Currency,
+			Category: item.Category,
+			Account:  item.Account,
+			Date:     item.Date,
+			Remark:   item.Remark,
+			Tags:     tagsFromNames(item.Tags),
+			Splits:   splitsFromInput(item.Splits),
+		}})
+	}
+
+	results, err := entryService.BatchUpdateEntries(c.Request.Context(), userID.(uint), items, input.Mode != BatchModePartial)
+	respondBatch(c, results, err, "批量修改失败")
+}
+
+// BatchDeleteEntries - 批量删除账单
+func BatchDeleteEntries(c *gin.Context) {
+	var input BatchDeleteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	items := make([]services.BatchItem, 0, len(input.Items))
+	for _, item := range input.Items {
+		items = append(items, services.BatchItem{ID: item.ID, Version: item.Version})
+	}
+
+	results, err := entryService.BatchDeleteEntries(c.Request.Context(), userID.(uint), items, input.Mode != BatchModePartial)
+	respondBatch(c, results, err, "批量删除失败")
+}
+
+// decodeBatchItem 解析并校验批量请求中的一项，错误转换为 utils.FieldErrors
+// 字段名与单条接口一致，使用 json 标签中的名称（如 ledger_id）；拆分明细的错误归到 splits 下并注明行号
+func decodeBatchItem(raw json.RawMessage, item interface{}) error {
+	if err := json.Unmarshal(raw, item); err != nil {
+		return utils.FieldErrors{"item": "格式错误: " + err.Error()}
+	}
+	err := binding.Validator.ValidateStruct(item)
+	if err == nil {
+		return nil
+	}
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return utils.FieldErrors{"item": err.Error()}
+	}
+	fields := utils.FieldErrors{}
+	for _, fe := range validationErrors {
+		var msg string
+		switch fe.Tag() {
+		case "required":
+			msg = "不能为空"
+		case "max":
+			msg = "不能超过 " + fe.Param() + " 个字符"
+		default:
+			msg = "校验失败: " + fe.Tag()
+		}
+		path := jsonFieldPath(reflect.TypeOf(item), fe.StructNamespace())
+		if len(path) == 0 {
+			fields["item"] = msg
+			continue
+		}
+		// 嵌套字段如 splits[1].amount -> splits: 第 2 行 amount 不能为空
+		name, index, nested := path[0].name, path[0].index, path[1:]
+		for _, field := range nested {
+			msg = field.name + " " + msg
+		}
+		if index >= 0 && len(nested) > 0 {
+			msg = fmt.Sprintf("第 %d 行 %s", index+1, msg)
+		}
+		fields[name] = msg
+	}
+	return fields
+}
+
+// jsonField 校验错误路径中的一段：json 名称及数组下标（不是数组时为 -1）
+type jsonField struct {
+	name  string
+	index int
+}
+
+// jsonFieldPath 把 StructNamespace（如 BatchUpdateItemInput.UpdateEntryInput.Splits[1].Amount）转换为 json 字段路径
+// 嵌入的结构体没有 json 名称，不出现在路径中
+func jsonFieldPath(t reflect.Type, namespace string) []jsonField {
+	parts := strings.Split(namespace, ".")
+	var path []jsonField
+	for _, part := range parts[1:] { // 第一段是根结构体的类型名
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return path
+		}
+		index := -1
+		if i := strings.Index(part, "["); i >= 0 {
+			if n, err := strconv.Atoi(strings.TrimSuffix(part[i+1:], "]")); err == nil {
+				index = n
+			}
+			part = part[:i]
+		}
+		field, ok := t.FieldByName(part)
+		if !ok {
+			return path
+		}
+		t = field.Type
+		if field.Anonymous {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			name = strings.ToLower(field.Name)
+		}
+		path = append(path, jsonField{name: name, index: index})
+	}
+	return path
+}
+
+// respondBatch 返回批量操作的结果
+// 整体模式失败时按失败项的错误返回对应状态码，results 中列出失败项；否则返回 200 和每一项的结果
+func respondBatch(c *gin.Context, results []services.BatchResult, err error, fallback string) {
+	if err != nil && !errors.Is(err, services.ErrBatchAborted) {
+		respondError(c, err, fallback)
+		return
+	}
+
+	items := make([]gin.H, 0, len(results))
+	status := http.StatusOK
+	succeeded := 0
+	for _, result := range results {
+		if result.Err == nil {
+			succeeded++
+			item := gin.H{"index": result.Index, "status": http.StatusOK}
+			if result.Entry != nil {
+				item["data"] = result.Entry
+			}
+			items = append(items, item)
+			continue
+		}
+		code, body := errorResponse(result.Err, fallback)
+		body["index"] = result.Index
+		body["status"] = code
+		items = append(items, body)
+		if status == http.StatusOK {
+			status = code
+		}
+	}
+
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error(), "results": items})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   items,
+	}})
+}
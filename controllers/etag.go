@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go-ledger/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// entryETag 账单的 ETag，即带引号的版本号 sync_seq，账单每次变更都会递增
+func entryETag(entry *models.LedgerEntry) string {
+	return `"` + strconv.FormatUint(entry.SyncSeq, 10) + `"`
+}
+
+// ifMatchVersion 读取 If-Match 请求头中的账单版本，修改、删除账单时必须携带
+// 缺少请求头时返回 428；If-Match: * 表示不校验版本，返回 nil
+// 格式无法识别的 ETag 不可能与任何版本匹配，直接返回 412
+func ifMatchVersion(c *gin.Context) (*uint64, bool) {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "缺少 If-Match 请求头，请先获取账单的 ETag"})
+		return nil, false
+	}
+	if value == "*" {
+		return nil, true
+	}
+	// If-Match 使用强比较，弱 ETag（W/ 前缀）不参与匹配
+	version, err := strconv.ParseUint(strings.Trim(value, `"`), 10, 64)
+	if err != nil || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match 与账单当前版本不一致"})
+		return nil, false
+	}
+	return &version, true
+}
+
+// notModified 比较 If-None-Match 与 etag，一致时写出 304 并返回 true
+// 按弱比较处理，支持逗号分隔的多个 ETag 和 *
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithETag 以响应体的摘要作为弱 ETag 返回 JSON 列表，内容没有变化时客户端可以用 If-None-Match 跳过下载
+func respondWithETag(c *gin.Context, body gin.H) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+		return
+	}
+	sum := sha256.Sum256(data)
+	if notModified(c, `W/"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GoalInput 创建 / 修改储蓄目标的输入参数，account_id 和 tag_id 二选一
+type GoalInput struct {
+	LedgerID     uint       `json:"ledger_id"` // 所属账本，不传则使用当前账本；修改时忽略
+	Name         string     `json:"name" binding:"required"`
+	TargetAmount float64    `json:"target_amount" binding:"required"`
+	Currency     string     `json:"currency"` // 不传则使用本位币，修改时不传保持原币种
+	StartDate    *time.Time `json:"start_date"`
+	Deadline     *time.Time `json:"deadline"`
+	AccountID    *uint      `json:"account_id"` // 资产科目，按该资金账户的净流入计算进度
+	TagID        *uint      `json:"tag_id"`     // 标签，带该标签的账单金额都计为存入
+	Remark       string     `json:"remark"`
+}
+
+func (input GoalInput) goal() models.Goal {
+	return models.Goal{
+		LedgerID:     input.LedgerID,
+		Name:         input.Name,
+		TargetAmount: input.TargetAmount,
+		Currency:     input.Currency,
+		StartDate:    input.StartDate,
+		Deadline:     input.Deadline,
+		AccountID:    input.AccountID,
+		TagID:        input.TagID,
+		Remark:       input.Remark,
+	}
+}
+
+var goalService = new(services.GoalService)
+
+// CreateGoal - 创建储蓄目标
+func CreateGoal(c *gin.Context) {
+	var input GoalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	goal := input.goal()
+	if err := goalService.CreateGoal(userID.(uint), &goal); err != nil {
+		respondError(c, err, "创建失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": goal})
+}
+
+// ListGoals - 查询储蓄目标及进度
+func ListGoals(c *gin.Context) {
+	var filter models.GoalFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	goals, err := goalService.ListGoals(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": goals})
+}
+
+// GetGoal - 查询储蓄目标详情
+func GetGoal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	goal, err := goalService.GetGoal(id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": goal})
+}
+
+// UpdateGoal - 修改储蓄目标
+func UpdateGoal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input GoalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	update := input.goal()
+	goal, err := goalService.UpdateGoal(id, userID.(uint), &update)
+	if err != nil {
+		respondError(c, err, "更新失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": goal})
+}
+
+// DeleteGoal - 删除储蓄目标
+func DeleteGoal(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := goalService.DeleteGoal(id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
+
+// ListGoalEntries - 分页查询计入储蓄目标的账单
+func ListGoalEntries(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+	page, pageSize := utils.GetPageParams(c)
+
+	entries, total, err := goalService.GoalEntries(id, userID.(uint), page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+		"meta": gin.H{
+			"current_page": page,
+			"page_size":    pageSize,
+			"total":        total,
+			"total_pages":  (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
@@ -0,0 +1,292 @@
+package controllers
+
+import (
+	"errors"
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateLedgerInput 创建账本的输入参数
+type CreateLedgerInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// InviteMemberInput 邀请成员的输入参数
+type InviteMemberInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// RenameLedgerInput 重命名账本的输入参数
+type RenameLedgerInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// ClosePeriodInput 结账、重新打开账期的输入参数
+type ClosePeriodInput struct {
+	ClosedBefore string `json:"closed_before"` // 结账日期 YYYY-MM-DD，该日期之前的账单不能再修改；重新打开时不传表示取消结账
+}
+
+// UpdateMemberInput 修改成员角色的输入参数
+type UpdateMemberInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+var ledgerService = new(services.LedgerService)
+
+// CreateLedger - 创建账本
+func CreateLedger(c *gin.Context) {
+	var input CreateLedgerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	ledger, err := ledgerService.CreateLedger(userID.(uint), input.Name)
+	if err != nil {
+		respondError(c, err, "创建账本失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": ledger})
+}
+
+// ListLedgers - 查询当前用户加入的账本
+func ListLedgers(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	includeArchived := c.Query("include_archived") == "true"
+	ledgers, err := ledgerService.ListLedgers(userID.(uint), includeArchived)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": ledgers})
+}
+
+// RenameLedger - 重命名账本
+func RenameLedger(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input RenameLedgerInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := ledgerService.RenameLedger(ledgerID, userID.(uint), input.Name); err != nil {
+		respondError(c, err, "修改失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "修改成功"})
+}
+
+// ArchiveLedger - 归档账本
+func ArchiveLedger(c *gin.Context) {
+	archiveLedger(c, true)
+}
+
+// UnarchiveLedger - 取消归档
+func UnarchiveLedger(c *gin.Context) {
+	archiveLedger(c, false)
+}
+
+func archiveLedger(c *gin.Context, archived bool) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := ledgerService.ArchiveLedger(ledgerID, userID.(uint), archived); err != nil {
+		respondError(c, err, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "操作成功"})
+}
+
+// ClosePeriod - 结账
+func ClosePeriod(c *gin.Context) {
+	setClosedBefore(c, true)
+}
+
+// ReopenPeriod - 重新打开已结账的账期
+func ReopenPeriod(c *gin.Context) {
+	setClosedBefore(c, false)
+}
+
+func setClosedBefore(c *gin.Context, closing bool) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	// 重新打开时请求体可以为空，表示取消结账
+	var input ClosePeriodInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var before *time.Time
+	if input.ClosedBefore != "" || closing {
+		date, ok := utils.ParseDate(input.ClosedBefore)
+		if !ok {
+			respondError(c, utils.FieldErrors{"closed_before": "日期格式必须为 YYYY-MM-DD"}, "操作失败")
+			return
+		}
+		before = &date
+	}
+	userID, _ := c.Get("userID")
+
+	var ledger *models.Ledger
+	var err error
+	if closing {
+		ledger, err = ledgerService.ClosePeriod(c.Request.Context(), ledgerID, userID.(uint), *before)
+	} else {
+		ledger, err = ledgerService.ReopenPeriod(c.Request.Context(), ledgerID, userID.(uint), before)
+	}
+	if err != nil {
+		respondError(c, err, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": ledger})
+}
+
+// SwitchLedger - 切换当前账本
+func SwitchLedger(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := ledgerService.SwitchLedger(ledgerID, userID.(uint)); err != nil {
+		respondError(c, err, "切换失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "切换成功"})
+}
+
+// ListLedgerMembers - 查询账本成员
+func ListLedgerMembers(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	members, err := ledgerService.ListMembers(ledgerID, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": members})
+}
+
+// InviteLedgerMember - 按用户名邀请成员
+func InviteLedgerMember(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input InviteMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	invitation, err := ledgerService.Invite(ledgerID, userID.(uint), input.Username, input.Role)
+	if err != nil {
+		respondError(c, err, "邀请失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": invitation})
+}
+
+// UpdateLedgerMember - 修改成员角色
+func UpdateLedgerMember(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+	var input UpdateMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := ledgerService.UpdateMemberRole(ledgerID, userID.(uint), memberID, input.Role); err != nil {
+		respondError(c, err, "修改失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "修改成功"})
+}
+
+// RemoveLedgerMember - 移除成员或退出账本
+func RemoveLedgerMember(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	memberID, ok := parseIDParam(c, "user_id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := ledgerService.RemoveMember(ledgerID, userID.(uint), memberID); err != nil {
+		respondError(c, err, "移除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "移除成功"})
+}
+
+// ListInvitations - 查询发给我的待处理邀请
+func ListInvitations(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	invitations, err := ledgerService.ListInvitations(userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": invitations})
+}
+
+// AcceptInvitation - 接受邀请
+func AcceptInvitation(c *gin.Context) {
+	respondInvitation(c, true)
+}
+
+// DeclineInvitation - 拒绝邀请
+func DeclineInvitation(c *gin.Context) {
+	respondInvitation(c, false)
+}
+
+func respondInvitation(c *gin.Context, accept bool) {
+	invitationID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := ledgerService.RespondInvitation(invitationID, userID.(uint), accept); err != nil {
+		respondError(c, err, "处理邀请失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "处理成功"})
+}
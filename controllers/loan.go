@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateLoanInput 创建借贷记录的输入参数
+type CreateLoanInput struct {
+	LedgerID     uint       `json:"ledger_id"` // 所属账本，不传则使用当前账本
+	Direction    string     `json:"direction" binding:"required"`
+	Counterparty string     `json:"counterparty" binding:"required"`
+	Principal    float64    `json:"principal" binding:"required"`
+	Currency     string     `json:"currency"` // 不传则使用本位币
+	Date         time.Time  `json:"date" binding:"required"`
+	DueDate      *time.Time `json:"due_date"`
+	Remark       string     `json:"remark"`
+}
+
+// AddRepaymentInput 登记还款的输入参数
+type AddRepaymentInput struct {
+	Amount float64   `json:"amount" binding:"required"`
+	Date   time.Time `json:"date" binding:"required"`
+	Remark string    `json:"remark"`
+}
+
+var loanService = new(services.LoanService)
+
+// CreateLoan - 创建借贷记录
+func CreateLoan(c *gin.Context) {
+	var input CreateLoanInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	loan := models.Loan{
+		LedgerID:     input.LedgerID,
+		Direction:    input.Direction,
+		Counterparty: input.Counterparty,
+		Principal:    input.Principal,
+		Currency:     input.Currency,
+		Date:         input.Date,
+		DueDate:      input.DueDate,
+		Remark:       input.Remark,
+	}
+	if err := loanService.CreateLoan(c.Request.Context(), userID.(uint), &loan); err != nil {
+		respondError(c, err, "创建失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": loan})
+}
+
+// ListLoans - 查询借贷记录
+func ListLoans(c *gin.Context) {
+	var filter models.LoanFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	loans, err := loanService.ListLoans(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": loans})
+}
+
+// GetLoan - 查询借贷详情及还款历史
+func GetLoan(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	loan, err := loanService.GetLoan(id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": loan})
+}
+
+// AddLoanRepayment - 登记还款
+func AddLoanRepayment(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input AddRepaymentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	repayment := models.LoanRepayment{Amount: input.Amount, Date: input.Date, Remark: input.Remark}
+	loan, err := loanService.AddRepayment(c.Request.Context(), id, userID.(uint), &repayment)
+	if err != nil {
+		respondError(c, err, "登记失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": loan})
+}
+
+// DeleteLoan - 删除借贷记录
+func DeleteLoan(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := loanService.DeleteLoan(c.Request.Context(), id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
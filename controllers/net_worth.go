@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"errors"
+	"go-ledger/models"
+	"go-ledger/services"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NetWorthSnapshotInput 手动生成净资产快照的输入参数
+type NetWorthSnapshotInput struct {
+	Date string `json:"date"` // YYYY-MM-DD，不传则为今天
+}
+
+// NetWorthQuery 净资产明细的查询参数
+type NetWorthQuery struct {
+	Date string `form:"date"` // 取该日期当天或之前最近的快照，不传则为最新快照
+}
+
+var netWorthService = new(services.NetWorthService)
+
+// TakeNetWorthSnapshot - 立即生成净资产快照
+func TakeNetWorthSnapshot(c *gin.Context) {
+	var input NetWorthSnapshotInput
+	// 请求体可以为空
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	breakdown, err := netWorthService.TakeSnapshot(userID.(uint), input.Date)
+	if err != nil {
+		respondError(c, err, "生成失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": breakdown})
+}
+
+// GetNetWorth - 查询净资产的资产、负债明细
+func GetNetWorth(c *gin.Context) {
+	var query NetWorthQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	breakdown, err := netWorthService.GetBreakdown(userID.(uint), query.Date)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": breakdown})
+}
+
+// GetNetWorthHistory - 查询净资产走势
+func GetNetWorthHistory(c *gin.Context) {
+	var filter models.NetWorthFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	points, err := netWorthService.History(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": points})
+}
@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartReconciliationInput 开始对账的输入参数
+type StartReconciliationInput struct {
+	AccountID        uint      `json:"account_id" binding:"required"` // 资产或负债科目
+	StatementDate    time.Time `json:"statement_date" binding:"required"`
+	StatementBalance float64   `json:"statement_balance"`
+	OpeningBalance   float64   `json:"opening_balance"` // 首次对账时的期初余额，之后沿用上一次的对账单余额
+	Currency         string    `json:"currency"`        // 首次对账不传则使用本位币，之后沿用上一次的币种
+}
+
+// ClearEntriesInput 勾选账单的输入参数
+type ClearEntriesInput struct {
+	EntryIDs []uint `json:"entry_ids" binding:"required"`
+	Cleared  *bool  `json:"cleared"` // 不传为 true；false 表示取消勾选
+}
+
+var reconciliationService = new(services.ReconciliationService)
+
+// StartReconciliation - 开始对账
+func StartReconciliation(c *gin.Context) {
+	var input StartReconciliationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	rec := models.Reconciliation{
+		AccountID:        input.AccountID,
+		StatementDate:    input.StatementDate,
+		StatementBalance: input.StatementBalance,
+		OpeningBalance:   input.OpeningBalance,
+		Currency:         input.Currency,
+	}
+	if err := reconciliationService.StartReconciliation(userID.(uint), &rec); err != nil {
+		respondError(c, err, "创建失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rec})
+}
+
+// ListReconciliations - 查询对账记录
+func ListReconciliations(c *gin.Context) {
+	var filter models.ReconciliationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	recs, err := reconciliationService.ListReconciliations(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": recs})
+}
+
+// GetReconciliation - 查询对账详情及可勾选的账单
+func GetReconciliation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	detail, err := reconciliationService.GetReconciliation(id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": detail})
+}
+
+// ClearReconciliation - 勾选或取消勾选账单
+func ClearReconciliation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input ClearEntriesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	cleared := input.Cleared == nil || *input.Cleared
+	rec, err := reconciliationService.ClearEntries(c.Request.Context(), id, userID.(uint), input.EntryIDs, cleared)
+	if err != nil {
+		respondError(c, err, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rec})
+}
+
+// FinishReconciliation - 完成对账
+func FinishReconciliation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	rec, err := reconciliationService.FinishReconciliation(c.Request.Context(), id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "操作失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rec})
+}
+
+// CancelReconciliation - 取消进行中的对账
+func CancelReconciliation(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := reconciliationService.CancelReconciliation(c.Request.Context(), id, userID.(uint)); err != nil {
+		respondError(c, err, "取消失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "已取消"})
+}
+
+// UnlockEntry - 解锁已对账的账单
+func UnlockEntry(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	entry, err := reconciliationService.UnlockEntry(c.Request.Context(), id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "解锁失败")
+		return
+	}
+	c.Header("ETag", entryETag(entry))
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var reportService = new(services.ReportService)
+
+// GetSummary - 收支汇总报表
+func GetSummary(c *gin.Context) {
+	var filter models.ReportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	summary, err := reportService.Summary(userID.(uint), filter)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": summary})
+}
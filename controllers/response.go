@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"errors"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError 根据 Service 返回的错误类型选择 HTTP 状态码
+// 无法识别的错误统一返回 500 和 fallback 提示，避免把数据库错误暴露给前端
+func respondError(c *gin.Context, err error, fallback string) {
+	c.JSON(errorResponse(err, fallback))
+}
+
+// errorResponse 返回错误对应的 HTTP 状态码和响应体，批量接口用它生成每一项的结果
+func errorResponse(err error, fallback string) (int, gin.H) {
+	var fields utils.FieldErrors
+	switch {
+	case errors.As(err, &fields):
+		return http.StatusBadRequest, gin.H{"error": "参数校验失败", "fields": fields}
+	case errors.Is(err, services.ErrLedgerNotFound),
+		errors.Is(err, services.ErrEntryNotFound),
+		errors.Is(err, services.ErrInvitationNotFound),
+		errors.Is(err, services.ErrSharedExpenseNotFound),
+		errors.Is(err, services.ErrLoanNotFound),
+		errors.Is(err, services.ErrExchangeRateNotFound),
+		errors.Is(err, services.ErrTagNotFound),
+		errors.Is(err, services.ErrAttachmentNotFound),
+		errors.Is(err, services.ErrAccountNotFound),
+		errors.Is(err, services.ErrJournalNotFound),
+		errors.Is(err, services.ErrReconciliationNotFound),
+		errors.Is(err, services.ErrGoalNotFound),
+		errors.Is(err, services.ErrNetWorthSnapshotNotFound):
+		return http.StatusNotFound, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrLedgerForbidden):
+		return http.StatusForbidden, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrLedgerArchived),
+		errors.Is(err, services.ErrEntryLinked),
+		errors.Is(err, services.ErrTagExists),
+		errors.Is(err, services.ErrTagInUse),
+		errors.Is(err, services.ErrAttachmentLimit),
+		errors.Is(err, services.ErrAccountInUse),
+		errors.Is(err, services.ErrEntryLocked),
+		errors.Is(err, services.ErrPeriodClosed),
+		errors.Is(err, services.ErrReconciliationOpen),
+		errors.Is(err, services.ErrReconciliationFinished),
+		errors.Is(err, services.ErrReconciliationUnbalanced),
+		errors.Is(err, services.ErrSyncClientIDConflict):
+		return http.StatusConflict, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrAttachmentTooLarge):
+		return http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrAttachmentType):
+		return http.StatusUnsupportedMediaType, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrRateMissing):
+		return http.StatusUnprocessableEntity, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrEntryModified):
+		return http.StatusPreconditionFailed, gin.H{"error": err.Error()}
+	case errors.Is(err, services.ErrSyncTokenExpired):
+		return http.StatusGone, gin.H{"error": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": fallback}
+	}
+}
+
+// parseIDParam 解析路径中的 ID 参数，失败时直接返回 400
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 " + name})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SplitParticipantInput 分摊参与人
+type SplitParticipantInput struct {
+	UserID uint    `json:"user_id" binding:"required"`
+	Value  float64 `json:"value"` // exact 为金额，percent 为百分比，shares 为份数，equal 可不传
+}
+
+// CreateSharedExpenseInput 创建分摊支出的输入参数
+type CreateSharedExpenseInput struct {
+	PayerID      uint                    `json:"payer_id" binding:"required"`
+	Amount       float64                 `json:"amount" binding:"required"`
+	Currency     string                  `json:"currency"` // 不传则使用创建人的本位币
+	Category     string                  `json:"category" binding:"required"`
+	Date         time.Time               `json:"date" binding:"required"`
+	Remark       string                  `json:"remark"`
+	SplitMethod  string                  `json:"split_method" binding:"required"`
+	Participants []SplitParticipantInput `json:"participants" binding:"required,dive"`
+}
+
+// CreateSettlementInput 记录还款的输入参数
+type CreateSettlementInput struct {
+	ToUserID uint      `json:"to_user_id" binding:"required"`
+	Amount   float64   `json:"amount" binding:"required"`
+	Currency string    `json:"currency"` // 不传则使用本位币
+	Date     time.Time `json:"date" binding:"required"`
+	Remark   string    `json:"remark"`
+}
+
+var sharedExpenseService = new(services.SharedExpenseService)
+
+// CreateSharedExpense - 创建分摊支出
+func CreateSharedExpense(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input CreateSharedExpenseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	expense := models.SharedExpense{
+		LedgerID:    ledgerID,
+		PayerID:     input.PayerID,
+		Amount:      input.Amount,
+		Currency:    input.Currency,
+		Category:    input.Category,
+		Date:        input.Date,
+		Remark:      input.Remark,
+		SplitMethod: input.SplitMethod,
+	}
+	participants := make([]services.SplitParticipant, 0, len(input.Participants))
+	for _, p := range input.Participants {
+		participants = append(participants, services.SplitParticipant{UserID: p.UserID, Value: p.Value})
+	}
+
+	if err := sharedExpenseService.CreateSharedExpense(c.Request.Context(), userID.(uint), &expense, participants); err != nil {
+		respondError(c, err, "创建失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": expense})
+}
+
+// ListSharedExpenses - 分页查询账本中的分摊支出
+func ListSharedExpenses(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+	page, pageSize := utils.GetPageParams(c)
+
+	expenses, total, err := sharedExpenseService.ListSharedExpenses(ledgerID, userID.(uint), page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": expenses,
+		"meta": gin.H{
+			"current_page": page,
+			"page_size":    pageSize,
+			"total":        total,
+			"total_pages":  (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// DeleteSharedExpense - 删除分摊支出
+func DeleteSharedExpense(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := sharedExpenseService.DeleteSharedExpense(c.Request.Context(), id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
+
+// CreateSettlement - 记录向其他成员的还款
+func CreateSettlement(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input CreateSettlementInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	settlement := models.Settlement{
+		LedgerID: ledgerID,
+		ToUserID: input.ToUserID,
+		Amount:   input.Amount,
+		Currency: input.Currency,
+		Date:     input.Date,
+		Remark:   input.Remark,
+	}
+	if err := sharedExpenseService.CreateSettlement(userID.(uint), &settlement); err != nil {
+		respondError(c, err, "记录失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": settlement})
+}
+
+// GetBalances - 成员余额及结清方案
+func GetBalances(c *gin.Context) {
+	ledgerID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	balances, transfers, err := sharedExpenseService.Balances(ledgerID, userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"balances": balances, "transfers": transfers}})
+}
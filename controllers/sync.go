@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"encoding/json"
+	"go-ledger/models"
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var syncService = new(services.SyncService)
+
+// 每次拉取的默认和最大变更条数
+const (
+	syncDefaultLimit = 100
+	syncMaxLimit     = 500
+)
+
+// SyncPullQuery 拉取变更的查询参数
+type SyncPullQuery struct {
+	LedgerID uint   `form:"ledger_id"` // 不传则使用 token 中的账本，没有 token 时使用当前账本
+	Token    string `form:"token"`     // 上次拉取返回的 next_token，首次同步不传
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// SyncPushInput 推送离线变更的输入参数
+// changes 按原始 JSON 接收，逐项解析校验，单项格式错误不影响其他项
+type SyncPushInput struct {
+	LedgerID uint              `json:"ledger_id"` // 不传则使用当前账本
+	Changes  []json.RawMessage `json:"changes" binding:"required,min=1,max=100"`
+}
+
+// SyncChangeInput 推送的一条变更
+type SyncChangeInput struct {
+	ID       uint              `json:"id"`        // 服务端账单 ID，修改、删除服务端创建的账单时使用
+	ClientID string            `json:"client_id"` // 客户端生成的 UUID，客户端新建的账单使用
+	BaseSeq  *uint64           `json:"base_seq"`  // 客户端最后看到的 sync_seq，用 id 修改、删除时必填，新建时为 0 或不传
+	Deleted  bool              `json:"deleted"`
+	Entry    *UpdateEntryInput `json:"entry"` // 新建、修改时的账单内容，格式同修改账单
+}
+
+// PullChanges - 拉取账本中同步令牌之后的账单变更
+func PullChanges(c *gin.Context) {
+	var query SyncPullQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = syncDefaultLimit
+	}
+	userID, _ := c.Get("userID")
+
+	result, err := syncService.Pull(userID.(uint), query.LedgerID, query.Token, query.Limit)
+	if err != nil {
+		respondError(c, err, "同步失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// PushChanges - 推送客户端的离线变更，返回每条变更的处理结果（包括冲突）
+func PushChanges(c *gin.Context) {
+	var input SyncPushInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	changes := make([]models.SyncPushChange, 0, len(input.Changes))
+	for _, raw := range input.Changes {
+		var item SyncChangeInput
+		if err := decodeBatchItem(raw, &item); err != nil {
+			changes = append(changes, models.SyncPushChange{Err: err})
+			continue
+		}
+		if item.ID != 0 && item.BaseSeq == nil {
+			changes = append(changes, models.SyncPushChange{ID: item.ID, Err: utils.FieldErrors{"base_seq": "修改、删除服务端账单时不能为空"}})
+			continue
+		}
+		change := models.SyncPushChange{ID: item.ID, ClientID: item.ClientID, Deleted: item.Deleted}
+		if item.BaseSeq != nil {
+			change.BaseSeq = *item.BaseSeq
+		}
+		if item.Entry != nil {
+			change.Entry = &models.LedgerEntry{
+				Type:     item.Entry.Type,
+				Amount:   item.Entry.Amount,
+				Currency: item.Entry.Currency,
+				Category: item.Entry.Category,
+				Account:  item.Entry.Account,
+				Date:     item.Entry.Date,
+				Remark:   item.Entry.Remark,
+				Tags:     tagsFromNames(item.Entry.Tags),
+				Splits:   splitsFromInput(item.Entry.Splits),
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	results, err := syncService.Push(c.Request.Context(), userID.(uint), input.LedgerID, changes)
+	if err != nil {
+		respondError(c, err, "同步失败")
+		return
+	}
+
+	// 出错的变更在结果中附带与单条接口相同的 error、fields，以及对应的 HTTP 状态码 code
+	items := make([]interface{}, 0, len(results))
+	for _, result := range results {
+		if result.Err == nil {
+			items = append(items, result)
+			continue
+		}
+		code, body := errorResponse(result.Err, "同步失败")
+		body["index"] = result.Index
+		body["status"] = result.Status
+		body["code"] = code
+		body["id"] = result.ID
+		body["client_id"] = result.ClientID
+		items = append(items, body)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"go-ledger/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RenameTagInput 重命名标签的输入参数
+type RenameTagInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeTagInput 合并标签的输入参数
+type MergeTagInput struct {
+	TargetID uint `json:"target_id" binding:"required"` // 合并到的目标标签
+}
+
+var tagService = new(services.TagService)
+
+// ListTags - 查询账本中的标签
+func ListTags(c *gin.Context) {
+	ledgerID, err := strconv.ParseUint(c.DefaultQuery("ledger_id", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	tags, err := tagService.ListTags(uint(ledgerID), userID.(uint))
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// RenameTag - 重命名标签
+func RenameTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input RenameTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	tag, err := tagService.RenameTag(id, userID.(uint), input.Name)
+	if err != nil {
+		respondError(c, err, "修改失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tag})
+}
+
+// MergeTag - 把标签合并到另一个标签
+func MergeTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var input MergeTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	tag, err := tagService.MergeTag(id, input.TargetID, userID.(uint))
+	if err != nil {
+		respondError(c, err, "合并失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tag})
+}
+
+// DeleteTag - 删除标签
+func DeleteTag(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := tagService.DeleteTag(id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "删除成功"})
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"go-ledger/services"
+	"go-ledger/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var trashService = new(services.TrashService)
+
+// TrashQuery 回收站的查询参数
+type TrashQuery struct {
+	LedgerID uint `form:"ledger_id"` // 不传则使用当前账本
+}
+
+// ListTrash - 查询账本回收站中的账单
+func ListTrash(c *gin.Context) {
+	var query TrashQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+	page, pageSize := utils.GetPageParams(c)
+
+	entries, total, err := trashService.ListTrash(userID.(uint), query.LedgerID, page, pageSize)
+	if err != nil {
+		respondError(c, err, "查询失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": entries,
+		"meta": gin.H{
+			"current_page": page,
+			"page_size":    pageSize,
+			"total":        total,
+			"total_pages":  (total + int64(pageSize) - 1) / int64(pageSize),
+		},
+	})
+}
+
+// RestoreEntry - 从回收站恢复账单
+func RestoreEntry(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	entry, err := trashService.RestoreEntry(c.Request.Context(), id, userID.(uint))
+	if err != nil {
+		respondError(c, err, "恢复失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": entry})
+}
+
+// PurgeEntry - 彻底删除回收站中的账单
+func PurgeEntry(c *gin.Context) {
+	id, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	if err := trashService.PurgeEntry(c.Request.Context(), id, userID.(uint)); err != nil {
+		respondError(c, err, "删除失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": "已彻底删除"})
+}
+
+// EmptyTrash - 清空账本回收站
+func EmptyTrash(c *gin.Context) {
+	var query TrashQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+	userID, _ := c.Get("userID")
+
+	purged, err := trashService.EmptyTrash(c.Request.Context(), userID.(uint), query.LedgerID)
+	if err != nil {
+		respondError(c, err, "清空失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"purged": purged}})
+}
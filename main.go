@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"go-ledger/config"
 	"go-ledger/routers"
+	"go-ledger/services"
+	"go-ledger/storage"
+	"go-ledger/utils"
 	"os"
 )
 
@@ -15,8 +18,22 @@ func main() {
 	}
 	// 先加载配置文件
 	config.InitConfig()
+	// 配置加载完成后再初始化 JWT 密钥，空密钥或弱密钥直接拒绝启动
+	if err := utils.InitJWT(); err != nil {
+		panic("JWT 密钥初始化失败: " + err.Error())
+	}
+	// 初始化附件存储驱动
+	if err := storage.Init(); err != nil {
+		panic("存储初始化失败: " + err.Error())
+	}
 	// 再初始化数据库连接
 	config.InitDB()
+	// 启动回收站自动清理
+	services.StartTrashPurge()
+	// 启动过期幂等记录清理
+	services.StartIdempotencyPurge()
+	// 启动净资产自动快照
+	services.StartNetWorthSnapshots()
 	r := routers.SetupRouter()
 	listenAddr := fmt.Sprintf("0.0.0.0:%s", port)
 	fmt.Printf("服务正在监听地址：%s\n", listenAddr)
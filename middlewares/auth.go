@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"go-ledger/services"
 	"go-ledger/utils"
 	"net/http"
 	"strings"
@@ -38,6 +39,8 @@ func JwtAuthMiddleware() gin.HandlerFunc {
 		// 4. 将 userID 存入上下文，供后续 Controller 使用
 		// 注意：这里存进去的是 float64 (JWT默认)，取的时候要注意转换，或者在 ParseToken 里转成 uint
 		c.Set("userID", uint(userID))
+		// 同时记入请求的 context，Service 写审计日志时作为操作人
+		c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), uint(userID)))
 
 		// 5. 放行，进入下一个 Handler
 		c.Next()
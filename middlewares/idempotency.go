@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-ledger/services"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader   = "Idempotency-Key"
+	replayedHeader      = "Idempotent-Replayed"
+	idempotencyKeyMax   = 255
+	idempotencyMaxBytes = 1 << 20 // 超过该大小的响应不保存，请求结束后释放 key
+	// 参与摘要的请求体上限，超过时返回 413；multipart 上传按附件大小上限另行限制
+	idempotencyMaxRequestBytes = 2 << 20
+	// multipart 请求体中除文件内容外，边界和各部分头部允许的额外大小，与附件上传接口一致
+	idempotencyMultipartOverhead = 1 << 20
+)
+
+var idempotencyService = new(services.IdempotencyService)
+
+// IdempotencyMiddleware 为带 Idempotency-Key 请求头的 POST 请求提供幂等保证，需要挂在 JWT 中间件之后
+// 首次请求正常执行并保存响应；有效期内用相同 key 重试时直接返回保存的响应（响应头 Idempotent-Replayed: true），
+// 不会重复记账或重复调用 AI；相同 key 携带不同的请求内容时返回 422，前一个请求仍在处理中时返回 409
+// 5xx 响应不保存，客户端可以用同一个 key 重试
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMax {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key 不能超过 255 个字符"})
+			return
+		}
+		userID := c.MustGet("userID").(uint)
+
+		// 1. 计算请求摘要
+		requestHash, cleanup, err := idempotencyRequestHash(c)
+		defer cleanup()
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求体过大"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			return
+		}
+
+		// 2. 占用 key，已完成的请求直接重放响应
+		record, err := idempotencyService.Begin(userID, key, requestHash)
+		switch {
+		case errors.Is(err, services.ErrIdempotencyMismatch):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrIdempotencyInProgress):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "服务器内部错误"})
+			return
+		case record != nil:
+			c.Header(replayedHeader, "true")
+			c.Data(record.Status, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		// 3. 执行请求并保存响应；Handler panic 时也要释放 key，否则重试会一直返回 409 直到记录过期
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := idempotencyService.Release(userID, key); err != nil {
+				log.Printf("释放 Idempotency-Key 失败: %v", err)
+			}
+		}()
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || writer.body.Len() > idempotencyMaxBytes {
+			return
+		}
+		if err := idempotencyService.Complete(userID, key, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Printf("保存 Idempotency-Key 响应失败: %v", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// idempotencyRequestHash 计算请求方法、路径和请求体的 SHA-256
+// 普通请求体最多读取 idempotencyMaxRequestBytes，读完后放回去供后续 Handler 使用；
+// multipart 上传（附件、汇率文件）边读边计算摘要并写入临时文件，不把整个文件读进内存，
+// 返回的 cleanup 负责删除临时文件，出错时也需要调用
+func idempotencyRequestHash(c *gin.Context) (string, func(), error) {
+	cleanup := func() {}
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	mediaType, params, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		limit := services.AttachmentMaxSize() + idempotencyMultipartOverhead
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		spool, err := os.CreateTemp("", "idempotency-*")
+		if err != nil {
+			return "", cleanup, err
+		}
+		cleanup = func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+		if err := hashMultipart(hash, io.TeeReader(body, spool), mediaType, params["boundary"]); err != nil {
+			return "", cleanup, err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return "", cleanup, err
+		}
+		c.Request.Body = spool
+		return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, idempotencyMaxRequestBytes))
+	if err != nil {
+		return "", cleanup, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
+
+// hashMultipart 按各部分的字段名、文件名和内容计算摘要，不包含边界
+// 客户端重试时可能生成新的边界，同样的字段和文件仍视为同一请求
+func hashMultipart(hash io.Writer, body io.Reader, mediaType, boundary string) error {
+	hash.Write([]byte(mediaType + "\n"))
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%q %q\n", part.FormName(), part.FileName())
+		size, err := io.Copy(hash, part)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "\n%d\n", size)
+	}
+	// 读完结尾部分，保证临时文件中是完整的请求体
+	_, err := io.Copy(io.Discard, body)
+	return err
+}
+
+// captureWriter 在写出响应的同时保留一份响应体
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,31 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"go-ledger/services"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// 客户端传入的请求 ID 只接受常见的 UUID / 追踪 ID 字符，避免把任意内容写进日志和审计表
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// RequestIDMiddleware 为每个请求分配请求 ID：沿用客户端或网关传入的 X-Request-ID，没有或格式不合法时生成新的
+// 请求 ID 写入响应头和上下文（requestID），并与客户端 IP 一起放入请求的 context，供 Service 写审计日志
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = rand.Text()
+		}
+		c.Set("requestID", id)
+		c.Header(requestIDHeader, id)
+
+		ctx := services.WithRequestMeta(c.Request.Context(), services.RequestMeta{RequestID: id, IP: c.ClientIP()})
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Attachment 账单附件（发票、小票等），文件内容保存在存储驱动中，这里只记录元数据
+// 删除附件时直接物理删除记录和文件
+type Attachment struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	EntryID     uint      `gorm:"not null;index" json:"entry_id"`
+	LedgerID    uint      `gorm:"not null;index" json:"ledger_id"`
+	UserID      uint      `gorm:"not null" json:"user_id"` // 上传人
+	FileName    string    `gorm:"type:varchar(255);not null" json:"file_name"`
+	ContentType string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size        int64     `gorm:"not null" json:"size"`
+	StorageKey  string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"-"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 审计动作
+const (
+	AuditActionLogin       = "login"        // 登录成功
+	AuditActionLoginFailed = "login_failed" // 登录失败（用户名不存在或密码错误）
+	AuditActionLoginLocked = "login_locked" // 登录失败次数过多被锁定
+
+	AuditActionEntryCreate  = "entry_create"  // 新增账单
+	AuditActionEntryUpdate  = "entry_update"  // 修改账单
+	AuditActionEntryDelete  = "entry_delete"  // 删除账单（移入回收站）
+	AuditActionEntryRestore = "entry_restore" // 从回收站恢复账单
+	AuditActionEntryPurge   = "entry_purge"   // 彻底删除账单
+	AuditActionEntryUnlock  = "entry_unlock"  // 解锁已对账的账单
+	AuditActionEntryClear   = "entry_clear"   // 在对账中勾选账单
+	AuditActionEntryUnclear = "entry_unclear" // 取消勾选账单（含取消对账）
+	AuditActionEntryLock    = "entry_lock"    // 完成对账时锁定账单
+
+	AuditActionPeriodClose  = "period_close"  // 账本结账
+	AuditActionPeriodReopen = "period_reopen" // 重新打开已结账的账期
+)
+
+// AuditLog 审计日志，只追加不修改，因此不嵌入 gorm.Model（不需要 UpdatedAt / DeletedAt）
+type AuditLog struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+	UserID    uint      `gorm:"index" json:"user_id"` // 操作人或相关用户，用户名不存在、系统自动执行时为 0
+	Username  string    `gorm:"type:varchar(50);index" json:"username"`
+	Action    string    `gorm:"type:varchar(50);not null;index" json:"action"`
+	IP        string    `gorm:"type:varchar(64)" json:"ip"`
+	RequestID string    `gorm:"type:varchar(64);index" json:"request_id"` // 对应请求的 X-Request-ID，便于与访问日志关联
+	Detail    string    `gorm:"type:varchar(255)" json:"detail"`
+	// 账单相关的动作记录账单所属账本、账单 ID 以及变更前后的账单快照（含标签和拆分明细）
+	// 新增时没有 Before，删除时没有 After；账单被彻底删除后历史记录仍然保留
+	LedgerID uint            `gorm:"index" json:"ledger_id,omitempty"`
+	EntryID  uint            `gorm:"index" json:"entry_id,omitempty"`
+	Before   json.RawMessage `gorm:"type:text" json:"before,omitempty"`
+	After    json.RawMessage `gorm:"type:text" json:"after,omitempty"`
+}
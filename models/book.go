@@ -0,0 +1,130 @@
+package models
+
+import "time"
+
+// 会计科目类型
+const (
+	AccountTypeAsset     = "asset"     // 资产：现金、银行卡、应收借款等
+	AccountTypeLiability = "liability" // 负债：信用卡、应付借款等
+	AccountTypeEquity    = "equity"    // 所有者权益：期初余额等
+	AccountTypeIncome    = "income"    // 收入
+	AccountTypeExpense   = "expense"   // 费用
+)
+
+// Account 复式记账的会计科目，每个账本有自己的科目表
+// 资产、负债科目按名称对应账单的资金账户（LedgerEntry.Account），收入、费用科目按名称对应账单分类
+// 科目被凭证引用后不能删除，因此不使用软删除
+type Account struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LedgerID  uint      `gorm:"not null;uniqueIndex:idx_ledger_account_code;uniqueIndex:idx_ledger_account_name" json:"ledger_id"`
+	Code      string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_ledger_account_code" json:"code"` // 科目编码，如 1001
+	Name      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_ledger_account_name" json:"name"`
+	Type      string    `gorm:"type:varchar(10);not null" json:"type"`
+	Remark    string    `gorm:"type:varchar(255)" json:"remark"`
+}
+
+// 默认科目的名称，账单换算为分录时使用
+const (
+	AccountNameCash       = "现金"   // 没有填写资金账户的账单记入该科目
+	AccountNameReceivable = "应收借款" // 借出、收回借款的对方科目
+	AccountNamePayable    = "应付借款" // 借入、偿还借款的对方科目
+)
+
+// DefaultAccounts 返回新建账本时自动创建的科目
+func DefaultAccounts(ledgerID uint) []Account {
+	return []Account{
+		{LedgerID: ledgerID, Code: "1001", Name: AccountNameCash, Type: AccountTypeAsset},
+		{LedgerID: ledgerID, Code: "1221", Name: AccountNameReceivable, Type: AccountTypeAsset},
+		{LedgerID: ledgerID, Code: "2241", Name: AccountNamePayable, Type: AccountTypeLiability},
+		{LedgerID: ledgerID, Code: "3001", Name: "期初权益", Type: AccountTypeEquity, Remark: "录入期初余额时的对方科目"},
+	}
+}
+
+// JournalTransaction 手工录入的记账凭证，借贷双方金额必须相等
+// 普通账单不生成凭证，统计时按规则换算为分录，见 EntryPostings
+type JournalTransaction struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LedgerID  uint      `gorm:"not null;index" json:"ledger_id"`
+	UserID    uint      `gorm:"not null" json:"user_id"` // 录入人
+	Date      time.Time `gorm:"type:date;not null;index" json:"date"`
+	Currency  string    `gorm:"type:char(3);not null" json:"currency"`
+	Memo      string    `gorm:"type:varchar(255)" json:"memo"`
+	Postings  []Posting `gorm:"foreignKey:TransactionID" json:"postings"`
+}
+
+// Posting 凭证的一条分录，Debit、Credit 有且只有一个大于 0
+type Posting struct {
+	ID            uint    `gorm:"primarykey" json:"id"`
+	TransactionID uint    `gorm:"not null;index" json:"transaction_id"`
+	AccountID     uint    `gorm:"not null;index" json:"account_id"`
+	Debit         float64 `gorm:"type:decimal(12,2);not null;default:0" json:"debit"`
+	Credit        float64 `gorm:"type:decimal(12,2);not null;default:0" json:"credit"`
+	Memo          string  `gorm:"type:varchar(255)" json:"memo"`
+}
+
+// EntryPosting 账单换算出的分录
+// 科目表中没有同名科目时 AccountID 为 0，按 Type + Name 作为临时科目统计
+type EntryPosting struct {
+	AccountID uint    `json:"account_id"`
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Debit     float64 `json:"debit"`
+	Credit    float64 `json:"credit"`
+	Currency  string  `json:"currency"`
+}
+
+// AccountBalance 科目在报表期间内的发生额和余额，金额已换算为本位币
+// 余额按科目的正常方向计算：资产、费用为借方减贷方，负债、权益、收入为贷方减借方
+type AccountBalance struct {
+	AccountID uint    `json:"account_id"` // 临时科目为 0
+	Code      string  `json:"code"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Opening   float64 `json:"opening"` // 期初余额
+	Debit     float64 `json:"debit"`   // 本期借方发生额
+	Credit    float64 `json:"credit"`  // 本期贷方发生额
+	Closing   float64 `json:"closing"` // 期末余额
+}
+
+// TrialBalance 试算平衡表：所有科目借方发生额合计等于贷方发生额合计
+type TrialBalance struct {
+	BaseCurrency string           `json:"base_currency"`
+	StartDate    string           `json:"start_date,omitempty"`
+	EndDate      string           `json:"end_date,omitempty"`
+	Accounts     []AccountBalance `json:"accounts"`
+	TotalDebit   float64          `json:"total_debit"`
+	TotalCredit  float64          `json:"total_credit"`
+	Balanced     bool             `json:"balanced"`
+}
+
+// BalanceSheet 资产负债表：截至 EndDate 的资产 = 负债 + 所有者权益
+// 收入、费用科目的累计差额作为"未分配利润"计入所有者权益
+type BalanceSheet struct {
+	BaseCurrency     string           `json:"base_currency"`
+	EndDate          string           `json:"end_date,omitempty"`
+	Assets           []AccountBalance `json:"assets"`
+	Liabilities      []AccountBalance `json:"liabilities"`
+	Equity           []AccountBalance `json:"equity"`
+	RetainedEarnings float64          `json:"retained_earnings"`
+	TotalAssets      float64          `json:"total_assets"`
+	TotalLiabilities float64          `json:"total_liabilities"`
+	TotalEquity      float64          `json:"total_equity"` // 含未分配利润
+	Balanced         bool             `json:"balanced"`
+}
+
+// IncomeStatement 利润表：期间内的收入、费用和净利润
+type IncomeStatement struct {
+	BaseCurrency string           `json:"base_currency"`
+	StartDate    string           `json:"start_date,omitempty"`
+	EndDate      string           `json:"end_date,omitempty"`
+	Income       []AccountBalance `json:"income"`
+	Expenses     []AccountBalance `json:"expenses"`
+	TotalIncome  float64          `json:"total_income"`
+	TotalExpense float64          `json:"total_expense"`
+	NetIncome    float64          `json:"net_income"`
+}
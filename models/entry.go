@@ -6,25 +6,76 @@ import (
 	"gorm.io/gorm"
 )
 
+// 账单类型
+const (
+	EntryTypeIncome  = 1 // 收入
+	EntryTypeExpense = 2 // 支出
+)
+
 type LedgerEntry struct {
 	gorm.Model
-	UserID uint `gorm:"not null;index" json:"user_id"` // 外键
-	Type   int  `gorm:"type:tinyint;not null;comment:1收入 2支出" json:"type"`
+	UserID   uint `gorm:"not null;index" json:"user_id"`                                                                                       // 创建人
+	LedgerID uint `gorm:"not null;default:0;index;index:idx_ledger_sync,priority:1;uniqueIndex:idx_ledger_client,priority:1" json:"ledger_id"` // 所属账本
+	Type     int  `gorm:"type:tinyint;not null;comment:1收入 2支出" json:"type"`
 	// 重点：使用 decimal 类型存储金额
-	Amount   float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
-	Category string    `gorm:"type:varchar(50);not null" json:"category"`
-	Date     time.Time `gorm:"type:date;not null" json:"date"`
-	Remark   string    `gorm:"type:varchar(255)" json:"remark"`
+	Amount   float64      `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Currency string       `gorm:"type:char(3);not null;default:CNY" json:"currency"` // 原始币种，Amount 为该币种下的金额
+	Category string       `gorm:"type:varchar(50);not null" json:"category"`
+	Account  string       `gorm:"type:varchar(50);not null;default:'';index" json:"account"` // 资金账户，如 "现金"、"招行信用卡"，可为空
+	Date     time.Time    `gorm:"type:date;not null" json:"date"`
+	Remark   string       `gorm:"type:varchar(255)" json:"remark"`
+	LoanID   *uint        `gorm:"index" json:"loan_id"`                                                       // 借贷产生的资金往来，不计入收支统计
+	ClientID *string      `gorm:"type:varchar(36);uniqueIndex:idx_ledger_client,priority:2" json:"client_id"` // 离线同步时客户端生成的 UUID，账本内唯一
+	SyncSeq  uint64       `gorm:"not null;default:0;index:idx_ledger_sync,priority:2" json:"sync_seq"`        // 账本内的变更序号，每次新增、修改、删除都会递增
+	Tags     []Tag        `gorm:"many2many:entry_tags;" json:"tags"`
+	Splits   []EntrySplit `gorm:"foreignKey:EntryID" json:"splits"` // 拆分明细，为空表示整笔记入 Category
+
+	// 对账：勾选为已核对时记录所属的对账，完成对账后锁定，锁定的账单需要先解锁才能修改、删除
+	ReconciliationID *uint `gorm:"index" json:"reconciliation_id"`
+	Locked           bool  `gorm:"not null;default:false" json:"locked"`
 
 	// 建立关联，方便查询
 	User User `gorm:"foreignKey:UserID" json:"-"`
 }
 
+// EntrySplit 账单拆分明细：一笔账单按分类拆成多行，各行金额之和等于账单金额
+// 分类统计按拆分行计入各自的分类，账单列表仍只显示一条父账单
+// 修改账单时整体替换，因此直接物理删除，不嵌入 gorm.Model
+type EntrySplit struct {
+	ID       uint    `gorm:"primarykey" json:"id"`
+	EntryID  uint    `gorm:"not null;index" json:"entry_id"`
+	Category string  `gorm:"type:varchar(50);not null" json:"category"`
+	Amount   float64 `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Remark   string  `gorm:"type:varchar(255)" json:"remark"`
+}
+
+// EntrySearchResult 全文搜索结果：账单及其相关度和高亮片段
+// Highlights 的 key 为命中的字段（remark、category），值为 HTML 转义后用 <em> 标出命中词的文本
+type EntrySearchResult struct {
+	LedgerEntry
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
 // EntryFilter 定义了支持的筛选参数
 // `form` tag 对应 URL 中的 ?key=value
+// 除账本外均按字符串接收，由 Service 严格校验，出错时返回具体的参数名
 type EntryFilter struct {
-	Type      int    `form:"type"`       // 1收入, 2支出 (0表示全部)
-	Category  string `form:"category"`   // 分类名称
-	StartDate string `form:"start_date"` // 开始日期 YYYY-MM-DD
-	EndDate   string `form:"end_date"`   // 结束日期 YYYY-MM-DD
+	LedgerID    uint   `form:"ledger_id"`    // 账本 ID (0表示当前账本)
+	Type        string `form:"type"`         // 1收入, 2支出 (空或0表示全部)
+	Category    string `form:"category"`     // 分类名称，多个用逗号分隔；账单本身或任一拆分行匹配即可
+	Account     string `form:"account"`      // 资金账户，多个用逗号分隔
+	MinAmount   string `form:"min_amount"`   // 金额下限（含）
+	MaxAmount   string `form:"max_amount"`   // 金额上限（含）
+	Keyword     string `form:"keyword"`      // 备注关键字
+	StartDate   string `form:"start_date"`   // 开始日期 YYYY-MM-DD
+	EndDate     string `form:"end_date"`     // 结束日期 YYYY-MM-DD
+	CreatedFrom string `form:"created_from"` // 创建时间下限，RFC3339 或 YYYY-MM-DD
+	CreatedTo   string `form:"created_to"`   // 创建时间上限，RFC3339 或 YYYY-MM-DD（含当天）
+	UpdatedFrom string `form:"updated_from"` // 修改时间下限
+	UpdatedTo   string `form:"updated_to"`   // 修改时间上限
+	TagsAny     string `form:"tags_any"`     // 包含其中任意一个标签，多个用逗号分隔
+	TagsAll     string `form:"tags_all"`     // 同时包含全部标签，多个用逗号分隔
+	Sort        string `form:"sort"`         // 排序字段：date(默认)、amount、created_at、updated_at
+	Order       string `form:"order"`        // 排序方向：desc(默认)、asc
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// DefaultCurrency 未设置本位币时使用的币种
+const DefaultCurrency = "CNY"
+
+// ExchangeRate 汇率：1 单位 FromCurrency = Rate 单位 ToCurrency
+// 按日期保留历史汇率，换算时使用账单日期当天或之前最近的一条
+// 汇率属于用户自己维护的数据，同一用户同一天同一币种对只保留一条
+type ExchangeRate struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_exchange_rate" json:"user_id"`
+	FromCurrency string    `gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rate" json:"from_currency"`
+	ToCurrency   string    `gorm:"type:char(3);not null;uniqueIndex:idx_exchange_rate" json:"to_currency"`
+	Date         time.Time `gorm:"type:date;not null;uniqueIndex:idx_exchange_rate" json:"date"`
+	Rate         float64   `gorm:"type:decimal(18,8);not null" json:"rate"`
+}
+
+// ExchangeRateFilter 汇率查询参数
+type ExchangeRateFilter struct {
+	FromCurrency string `form:"from_currency"`
+	ToCurrency   string `form:"to_currency"`
+	StartDate    string `form:"start_date"` // 开始日期 YYYY-MM-DD
+	EndDate      string `form:"end_date"`   // 结束日期 YYYY-MM-DD
+}
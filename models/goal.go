@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 储蓄目标状态，不落库，查询时根据进度计算
+const (
+	GoalStatusAchieved   = "achieved"    // 已达成
+	GoalStatusOnTrack    = "on_track"    // 按当前速度能在截止日期前达成
+	GoalStatusBehind     = "behind"      // 按当前速度无法在截止日期前达成
+	GoalStatusOverdue    = "overdue"     // 已过截止日期仍未达成
+	GoalStatusInProgress = "in_progress" // 没有截止日期
+)
+
+// Goal 储蓄目标，如旅行、买电脑
+// 关联一个资产科目或一个标签（二选一）：
+// 关联科目时进度为该科目的余额变动，包含账单换算出的分录和手工凭证；关联标签时带该标签的收入计为存入、支出计为取出
+type Goal struct {
+	gorm.Model
+	LedgerID     uint       `gorm:"not null;index" json:"ledger_id"`
+	UserID       uint       `gorm:"not null" json:"user_id"` // 创建人
+	Name         string     `gorm:"type:varchar(50);not null" json:"name"`
+	TargetAmount float64    `gorm:"type:decimal(12,2);not null" json:"target_amount"`
+	Currency     string     `gorm:"type:char(3);not null" json:"currency"` // 只统计该币种的账单和凭证
+	StartDate    *time.Time `gorm:"type:date" json:"start_date"`           // 从该日期起统计，为空表示统计全部
+	Deadline     *time.Time `gorm:"type:date" json:"deadline"`
+	AccountID    *uint      `gorm:"index" json:"account_id"`
+	TagID        *uint      `gorm:"index" json:"tag_id"`
+	Remark       string     `gorm:"type:varchar(255)" json:"remark"`
+
+	Saved           float64    `gorm:"-" json:"saved"`            // 已存入
+	Remaining       float64    `gorm:"-" json:"remaining"`        // 距目标还差
+	Percent         float64    `gorm:"-" json:"percent"`          // 完成百分比，可能超过 100
+	MonthlyRate     float64    `gorm:"-" json:"monthly_rate"`     // 最近几个月平均每月存入
+	RequiredMonthly *float64   `gorm:"-" json:"required_monthly"` // 截止日期前每月还需存入，没有截止日期或已过期时为空
+	EstimatedDate   *time.Time `gorm:"-" json:"estimated_date"`   // 按当前速度预计达成的日期，速度不大于 0 时为空
+	Status          string     `gorm:"-" json:"status"`
+}
+
+// GoalFilter 储蓄目标列表筛选参数
+type GoalFilter struct {
+	LedgerID uint `form:"ledger_id"` // 0 表示当前账本
+}
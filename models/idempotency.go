@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey 记录带 Idempotency-Key 请求头的写请求及其响应
+// 同一用户在有效期内用相同的 key 重试时直接返回保存的响应，不再重复执行
+// Status 为 0 表示请求仍在处理中
+type IdempotencyKey struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_user_idempotency_key"`
+	Key         string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_user_idempotency_key"`
+	RequestHash string    `gorm:"type:char(64);not null"` // 请求方法、路径和请求体的 SHA-256，用于识别 key 被用于不同的请求
+	Status      int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"type:varchar(100);not null;default:''"`
+	Body        []byte    `gorm:"type:mediumblob"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
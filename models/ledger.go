@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 账本成员角色
+const (
+	LedgerRoleOwner  = "owner"  // 所有者：管理成员、邀请
+	LedgerRoleEditor = "editor" // 编辑者：记账、删除账单
+	LedgerRoleViewer = "viewer" // 查看者：只读
+)
+
+// 邀请状态
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusDeclined = "declined"
+)
+
+// Ledger 账本，账单归属于账本，多个用户可以通过成员关系共享同一个账本
+type Ledger struct {
+	gorm.Model
+	Name       string     `gorm:"type:varchar(50);not null" json:"name"`
+	OwnerID    uint       `gorm:"not null;index" json:"owner_id"`
+	ArchivedAt *time.Time `json:"archived_at"` // 归档时间，归档后只读
+	// 结账日期：早于该日期的账单和记账凭证不能新增、修改、删除，为空表示没有结账
+	ClosedBefore *time.Time `gorm:"type:date" json:"closed_before"`
+	// 同步用：账单每次变更分配的最新序号，以及已被彻底删除的账单中最大的序号
+	// 客户端的同步位置早于 PurgedSeq 时可能漏掉删除，需要重新全量同步
+	SyncSeq   uint64 `gorm:"not null;default:0" json:"-"`
+	PurgedSeq uint64 `gorm:"not null;default:0" json:"-"`
+}
+
+// DefaultLedgerName 注册时自动创建的账本名称
+const DefaultLedgerName = "默认账本"
+
+// LedgerMember 账本成员关系
+// 移除成员时直接物理删除，因此不嵌入 gorm.Model，避免软删除记录与唯一索引冲突
+type LedgerMember struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LedgerID  uint      `gorm:"not null;uniqueIndex:idx_ledger_member" json:"ledger_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_ledger_member;index" json:"user_id"`
+	Role      string    `gorm:"type:varchar(10);not null" json:"role"`
+}
+
+// LedgerInvitation 账本邀请，被邀请人接受后成为成员
+type LedgerInvitation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LedgerID  uint      `gorm:"not null;index" json:"ledger_id"`
+	InviterID uint      `gorm:"not null" json:"inviter_id"`
+	InviteeID uint      `gorm:"not null;index" json:"invitee_id"`
+	Role      string    `gorm:"type:varchar(10);not null" json:"role"`
+	Status    string    `gorm:"type:varchar(10);not null;index" json:"status"`
+}
+
+// LedgerWithRole 账本列表项，附带当前用户在该账本中的角色
+type LedgerWithRole struct {
+	Ledger
+	Role    string `json:"role"`
+	Current bool   `gorm:"-" json:"current"` // 是否为当前切换到的账本
+}
+
+// LedgerMemberInfo 成员列表项
+type LedgerMemberInfo struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"joined_at"`
+}
+
+// InvitationInfo 邀请列表项
+type InvitationInfo struct {
+	ID              uint      `json:"id"`
+	LedgerID        uint      `json:"ledger_id"`
+	LedgerName      string    `json:"ledger_name"`
+	InviterUsername string    `json:"inviter_username"`
+	Role            string    `json:"role"`
+	CreatedAt       time.Time `json:"created_at"`
+}
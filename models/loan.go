@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 借贷方向
+const (
+	LoanDirectionLend   = "lend"   // 借出：我借钱给别人
+	LoanDirectionBorrow = "borrow" // 借入：别人借钱给我
+)
+
+// 借贷状态，overdue 不落库，查询时根据到期日计算
+const (
+	LoanStatusOpen    = "open"
+	LoanStatusSettled = "settled"
+	LoanStatusOverdue = "overdue"
+)
+
+// Loan 借贷记录
+// 借出 / 借入本金和每笔还款都会生成一条带 LoanID 的账单，这些账单不计入收支统计
+type Loan struct {
+	gorm.Model
+	LedgerID     uint            `gorm:"not null;index" json:"ledger_id"`
+	UserID       uint            `gorm:"not null;index" json:"user_id"` // 创建人
+	Direction    string          `gorm:"type:varchar(10);not null" json:"direction"`
+	Counterparty string          `gorm:"type:varchar(50);not null" json:"counterparty"` // 对方，可以不是系统用户
+	Principal    float64         `gorm:"type:decimal(10,2);not null" json:"principal"`
+	Currency     string          `gorm:"type:char(3);not null;default:CNY" json:"currency"` // 本金和还款账单使用的币种
+	Date         time.Time       `gorm:"type:date;not null" json:"date"`
+	DueDate      *time.Time      `gorm:"type:date" json:"due_date"`
+	Remark       string          `gorm:"type:varchar(255)" json:"remark"`
+	Status       string          `gorm:"type:varchar(10);not null;index" json:"status"`
+	EntryID      uint            `gorm:"not null" json:"entry_id"` // 本金对应的账单
+	Repayments   []LoanRepayment `gorm:"foreignKey:LoanID" json:"repayments,omitempty"`
+
+	Repaid      float64 `gorm:"-" json:"repaid"`      // 已还金额
+	Outstanding float64 `gorm:"-" json:"outstanding"` // 未还金额
+}
+
+// LoanRepayment 还款记录
+type LoanRepayment struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	LoanID    uint      `gorm:"not null;index" json:"loan_id"`
+	EntryID   uint      `gorm:"not null" json:"entry_id"` // 还款对应的账单
+	Amount    float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Date      time.Time `gorm:"type:date;not null" json:"date"`
+	Remark    string    `gorm:"type:varchar(255)" json:"remark"`
+}
+
+// LoanFilter 借贷列表筛选参数
+type LoanFilter struct {
+	LedgerID  uint   `form:"ledger_id"`
+	Direction string `form:"direction"` // lend / borrow
+	Status    string `form:"status"`    // open / settled / overdue
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// 净资产走势的时间粒度
+const (
+	NetWorthIntervalDay   = "day"
+	NetWorthIntervalMonth = "month"
+)
+
+// NetWorthSnapshot 用户某一天的净资产快照：截至当天，本人拥有的所有账本中资产、负债科目的余额合计
+// 借出、借入未还清的部分分别体现在"应收借款"、"应付借款"科目中；同一用户同一天只保留一份，重新生成时覆盖
+type NetWorthSnapshot struct {
+	ID               uint           `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UserID           uint           `gorm:"not null;uniqueIndex:idx_net_worth_user_date" json:"user_id"`
+	Date             time.Time      `gorm:"type:date;not null;uniqueIndex:idx_net_worth_user_date" json:"date"`
+	BaseCurrency     string         `gorm:"type:char(3);not null" json:"base_currency"` // 生成快照时的本位币
+	TotalAssets      float64        `gorm:"type:decimal(14,2);not null" json:"total_assets"`
+	TotalLiabilities float64        `gorm:"type:decimal(14,2);not null" json:"total_liabilities"`
+	NetWorth         float64        `gorm:"type:decimal(14,2);not null" json:"net_worth"` // 资产减负债
+	Fingerprint      string         `gorm:"type:char(64)" json:"-"`                       // 生成时影响净资产的数据版本，定时任务据此跳过没有变化的用户
+	Items            []NetWorthItem `gorm:"foreignKey:SnapshotID" json:"-"`
+}
+
+// NetWorthItem 快照中一个账本的一个资产或负债科目的余额，余额为 0 的科目不保存
+type NetWorthItem struct {
+	ID         uint    `gorm:"primarykey" json:"-"`
+	SnapshotID uint    `gorm:"not null;index" json:"-"`
+	LedgerID   uint    `gorm:"not null" json:"ledger_id"`
+	AccountID  uint    `gorm:"not null" json:"account_id"` // 临时科目为 0
+	Code       string  `gorm:"type:varchar(20)" json:"code"`
+	Name       string  `gorm:"type:varchar(50);not null" json:"name"`
+	Type       string  `gorm:"type:varchar(10);not null" json:"type"` // asset / liability
+	Balance    float64 `gorm:"type:decimal(14,2);not null" json:"balance"`
+}
+
+// NetWorthBreakdown 快照的资产、负债明细
+type NetWorthBreakdown struct {
+	NetWorthSnapshot
+	Assets      []NetWorthItem `json:"assets"`
+	Liabilities []NetWorthItem `json:"liabilities"`
+}
+
+// NetWorthPoint 净资产走势中的一个点，按月统计时取每月最后一份快照
+type NetWorthPoint struct {
+	Date             string  `json:"date"`
+	BaseCurrency     string  `json:"base_currency"`
+	TotalAssets      float64 `json:"total_assets"`
+	TotalLiabilities float64 `json:"total_liabilities"`
+	NetWorth         float64 `json:"net_worth"`
+}
+
+// NetWorthFilter 净资产走势的查询参数
+type NetWorthFilter struct {
+	StartDate string `form:"start_date"` // YYYY-MM-DD
+	EndDate   string `form:"end_date"`
+	Interval  string `form:"interval"` // day（默认）/ month
+}
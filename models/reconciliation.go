@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// 对账状态
+const (
+	ReconciliationStatusOpen     = "open"     // 进行中，可以勾选账单
+	ReconciliationStatusFinished = "finished" // 已完成，勾选的账单被锁定
+)
+
+// Reconciliation 资金科目（资产或负债，如信用卡）按对账单核对账单的记录
+// 每个科目同时只能有一个进行中的对账；期初余额为上一次完成对账的对账单余额，首次对账时由用户填写
+type Reconciliation struct {
+	ID               uint       `gorm:"primarykey" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	LedgerID         uint       `gorm:"not null;index" json:"ledger_id"`
+	AccountID        uint       `gorm:"not null;index" json:"account_id"`
+	UserID           uint       `gorm:"not null" json:"user_id"`               // 发起人
+	Currency         string     `gorm:"type:char(3);not null" json:"currency"` // 只核对该币种的账单
+	StatementDate    time.Time  `gorm:"type:date;not null" json:"statement_date"`
+	OpeningBalance   float64    `gorm:"type:decimal(12,2);not null" json:"opening_balance"`
+	StatementBalance float64    `gorm:"type:decimal(12,2);not null" json:"statement_balance"` // 对账单上的期末余额
+	Status           string     `gorm:"type:varchar(10);not null" json:"status"`
+	FinishedAt       *time.Time `json:"finished_at"`
+
+	ClearedCount   int64   `gorm:"-" json:"cleared_count"`   // 已勾选的账单数
+	ClearedBalance float64 `gorm:"-" json:"cleared_balance"` // 期初余额加上已勾选账单的金额
+	Difference     float64 `gorm:"-" json:"difference"`      // 对账单余额减已核对余额，为 0 时才能完成对账
+}
+
+// ReconciliationDetail 对账详情：对账信息和可以勾选的账单
+type ReconciliationDetail struct {
+	Reconciliation
+	Entries []LedgerEntry `json:"entries"`
+}
+
+// ReconciliationFilter 对账列表筛选参数
+type ReconciliationFilter struct {
+	LedgerID  uint   `form:"ledger_id"`
+	AccountID uint   `form:"account_id"`
+	Status    string `form:"status"` // open / finished
+}
@@ -0,0 +1,33 @@
+package models
+
+// ReportFilter 报表筛选参数
+type ReportFilter struct {
+	LedgerID  uint   `form:"ledger_id"`  // 账本 ID (0表示当前账本)
+	StartDate string `form:"start_date"` // 开始日期 YYYY-MM-DD
+	EndDate   string `form:"end_date"`   // 结束日期 YYYY-MM-DD
+}
+
+// CategoryTotal 按类型和分类汇总的金额
+type CategoryTotal struct {
+	Type     int     `json:"type"`
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+	Count    int64   `json:"count"`
+}
+
+// CurrencyTotal 按原始币种汇总的金额，未经换算
+type CurrencyTotal struct {
+	Currency string  `json:"currency"`
+	Income   float64 `json:"income"`
+	Expense  float64 `json:"expense"`
+}
+
+// ReportSummary 收支汇总，金额均已按账单日期的汇率换算为本位币
+type ReportSummary struct {
+	BaseCurrency string          `json:"base_currency"`
+	Income       float64         `json:"income"`
+	Expense      float64         `json:"expense"`
+	Balance      float64         `json:"balance"` // 收入 - 支出
+	Categories   []CategoryTotal `json:"categories"`
+	Currencies   []CurrencyTotal `json:"currencies"` // 各原始币种的金额
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 分摊方式
+const (
+	SplitMethodEqual   = "equal"   // 平均分摊
+	SplitMethodExact   = "exact"   // 指定金额
+	SplitMethodPercent = "percent" // 按百分比
+	SplitMethodShares  = "shares"  // 按份数
+)
+
+// SharedExpense 多人分摊的支出，一人付款，账本中的多个成员分摊
+// 每个参与人会在自己的当前账本中得到一条金额为其分摊额的支出账单
+type SharedExpense struct {
+	gorm.Model
+	LedgerID    uint                 `gorm:"not null;index" json:"ledger_id"` // 分摊所在的共享账本，参与人必须都是该账本成员
+	PayerID     uint                 `gorm:"not null;index" json:"payer_id"`  // 实际付款人
+	CreatedBy   uint                 `gorm:"not null" json:"created_by"`
+	Amount      float64              `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Currency    string               `gorm:"type:char(3);not null;default:CNY" json:"currency"` // 参与人的账单都使用该币种
+	Category    string               `gorm:"type:varchar(50);not null" json:"category"`
+	Date        time.Time            `gorm:"type:date;not null" json:"date"`
+	Remark      string               `gorm:"type:varchar(255)" json:"remark"`
+	SplitMethod string               `gorm:"type:varchar(10);not null" json:"split_method"`
+	Shares      []SharedExpenseShare `gorm:"foreignKey:SharedExpenseID" json:"shares"`
+}
+
+// SharedExpenseShare 每个参与人的分摊额
+type SharedExpenseShare struct {
+	ID              uint    `gorm:"primarykey" json:"id"`
+	SharedExpenseID uint    `gorm:"not null;index" json:"shared_expense_id"`
+	UserID          uint    `gorm:"not null;index" json:"user_id"`
+	Amount          float64 `gorm:"type:decimal(10,2);not null" json:"amount"`
+	EntryID         uint    `gorm:"not null" json:"entry_id"` // 参与人账本中对应的账单
+}
+
+// Settlement 成员之间的还款记录，用于抵消分摊产生的欠款
+type Settlement struct {
+	gorm.Model
+	LedgerID   uint      `gorm:"not null;index" json:"ledger_id"`
+	FromUserID uint      `gorm:"not null;index" json:"from_user_id"` // 还款人
+	ToUserID   uint      `gorm:"not null;index" json:"to_user_id"`   // 收款人
+	Amount     float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Currency   string    `gorm:"type:char(3);not null;default:CNY" json:"currency"` // 只抵消同一币种的欠款
+	Date       time.Time `gorm:"type:date;not null" json:"date"`
+	Remark     string    `gorm:"type:varchar(255)" json:"remark"`
+}
+
+// MemberBalance 成员在某一币种下的净余额，正数表示别人欠他，负数表示他欠别人
+type MemberBalance struct {
+	UserID   uint    `json:"user_id"`
+	Username string  `json:"username"`
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+}
+
+// Transfer 结清欠款所需的一笔转账，币种与欠款的币种相同
+type Transfer struct {
+	FromUserID   uint    `json:"from_user_id"`
+	FromUsername string  `json:"from_username"`
+	ToUserID     uint    `json:"to_user_id"`
+	ToUsername   string  `json:"to_username"`
+	Currency     string  `json:"currency"`
+	Amount       float64 `json:"amount"`
+}
@@ -0,0 +1,54 @@
+package models
+
+// 推送变更的处理结果
+const (
+	SyncStatusCreated  = "created"  // 新建了账单
+	SyncStatusUpdated  = "updated"  // 修改已生效
+	SyncStatusDeleted  = "deleted"  // 已删除（或服务端本来就已删除）
+	SyncStatusConflict = "conflict" // 服务端在客户端上次同步后已有变更，保留服务端版本
+	SyncStatusError    = "error"    // 变更无效或无权限，未生效
+)
+
+// SyncChange 变更流中的一条记录：账单在 SyncSeq 时的最新状态
+// Deleted 为 true 表示账单已删除，Entry 仍返回删除前的内容
+type SyncChange struct {
+	ID       uint         `json:"id"`
+	ClientID *string      `json:"client_id"`
+	SyncSeq  uint64       `json:"sync_seq"`
+	Deleted  bool         `json:"deleted"`
+	Entry    *LedgerEntry `json:"entry"`
+}
+
+// SyncPullResult 拉取变更的结果
+type SyncPullResult struct {
+	LedgerID  uint         `json:"ledger_id"`
+	Changes   []SyncChange `json:"changes"`
+	NextToken string       `json:"next_token"` // 下次拉取时传回，没有更多变更时也要保存
+	HasMore   bool         `json:"has_more"`   // 为 true 时应立即用 NextToken 继续拉取
+}
+
+// SyncPushChange 客户端推送的一条变更
+// 用 ID（服务端创建的账单）或 ClientID（客户端创建的账单）指定账单
+// BaseSeq 是客户端最后一次看到该账单时的 SyncSeq，新建的账单为 0
+type SyncPushChange struct {
+	ID       uint
+	ClientID string
+	BaseSeq  uint64
+	Deleted  bool
+	Entry    *LedgerEntry // 新建、修改时的内容，删除时为空
+	Err      error        // 解析或参数校验阶段已经失败，不会执行
+}
+
+// SyncPushResult 一条推送变更的处理结果，Index 对应请求中的下标
+// 冲突时 Reason 说明原因，Entry 为服务端当前版本，客户端应以它覆盖本地数据
+type SyncPushResult struct {
+	Index    int          `json:"index"`
+	Status   string       `json:"status"`
+	Reason   string       `json:"reason,omitempty"`
+	ID       uint         `json:"id,omitempty"`
+	ClientID *string      `json:"client_id,omitempty"`
+	SyncSeq  uint64       `json:"sync_seq,omitempty"`
+	Deleted  bool         `json:"deleted,omitempty"`
+	Entry    *LedgerEntry `json:"entry,omitempty"`
+	Err      error        `json:"-"`
+}
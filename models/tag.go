@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Tag 账单标签，属于账本，同一账本内名称唯一
+// 一条账单可以有多个标签，标签可以跨分类使用，如 "出差-2026"、"可报销"
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LedgerID  uint      `gorm:"not null;uniqueIndex:idx_ledger_tag" json:"ledger_id"`
+	Name      string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_ledger_tag" json:"name"`
+}
+
+// TagWithCount 标签列表项，附带使用该标签的账单数
+type TagWithCount struct {
+	Tag
+	EntryCount int64 `json:"entry_count"`
+}
@@ -6,4 +6,8 @@ type User struct {
 	gorm.Model
 	Username string `gorm:"type:varchar(50);unique;not null" json:"username"`
 	Password string `gorm:"type:varchar(100);not null" json:"-"` // json:"-" 避免密码被返回给前端
+	// 当前使用的账本，账单接口未指定 ledger_id 时默认使用该账本
+	CurrentLedgerID uint `gorm:"not null;default:0" json:"current_ledger_id"`
+	// 本位币，报表统一换算成该币种；新建账单未指定币种时也使用它
+	BaseCurrency string `gorm:"type:char(3);not null;default:CNY" json:"base_currency"`
 }
@@ -5,13 +5,25 @@ import (
 	"go-ledger/middlewares"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 )
 
 func SetupRouter() *gin.Engine {
 	r := gin.Default()
+	// 只信任配置的反向代理转发的 X-Forwarded-For / X-Real-IP，没有配置时直接使用连接的来源地址
+	// 否则客户端可以伪造 IP 绕过按 IP 的登录锁定，审计日志中的 IP 也不可信
+	var proxies []string
+	if viper.IsSet("server.trusted_proxies") {
+		proxies = viper.GetStringSlice("server.trusted_proxies")
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		panic("反向代理配置错误: " + err.Error())
+	}
 
 	// 注册跨域中间件
 	r.Use(middlewares.CorsMiddleware())
+	// 为每个请求分配请求 ID，审计日志按它关联请求
+	r.Use(middlewares.RequestIDMiddleware())
 
 	api := r.Group("/v1")
 	{
@@ -19,12 +31,106 @@ func SetupRouter() *gin.Engine {
 		api.POST("/register", controllers.Register) // 用户注册
 		api.POST("/login", controllers.Login)       // 用户登录
 		auth := api.Group("/")
-		auth.Use(middlewares.JwtAuthMiddleware()) // 挂载中间件
+		auth.Use(middlewares.JwtAuthMiddleware())     // 挂载中间件
+		auth.Use(middlewares.IdempotencyMiddleware()) // POST 请求支持 Idempotency-Key 幂等重试
 		{
-			auth.POST("/entries", controllers.CreateEntry)           // 记账
-			auth.POST("/entries/smart", controllers.CreateEntryByAI) // 智能记账 (AI)
-			auth.GET("/entries", controllers.FindEntries)            // 分页查询账单
-			auth.DELETE("/entries/:id", controllers.DeleteEntry)     // 删除账单
+			auth.POST("/entries", controllers.CreateEntry)                // 记账
+			auth.POST("/entries/smart", controllers.CreateEntryByAI)      // 智能记账 (AI)
+			auth.GET("/entries", controllers.FindEntries)                 // 分页查询账单
+			auth.GET("/entries/search", controllers.SearchEntries)        // 全文搜索账单
+			auth.GET("/entries/:id", controllers.GetEntry)                // 查询单条账单
+			auth.GET("/entries/:id/history", controllers.GetEntryHistory) // 账单变更历史
+			auth.PUT("/entries/:id", controllers.UpdateEntry)             // 修改账单
+			auth.DELETE("/entries/:id", controllers.DeleteEntry)          // 删除账单
+
+			auth.POST("/entries/batch", controllers.BatchCreateEntries)        // 批量新增账单
+			auth.PUT("/entries/batch", controllers.BatchUpdateEntries)         // 批量修改账单
+			auth.POST("/entries/batch/delete", controllers.BatchDeleteEntries) // 批量删除账单
+
+			auth.GET("/sync", controllers.PullChanges)  // 拉取账单变更
+			auth.POST("/sync", controllers.PushChanges) // 推送离线变更
+
+			auth.GET("/trash", controllers.ListTrash)                 // 回收站中的账单
+			auth.POST("/trash/:id/restore", controllers.RestoreEntry) // 恢复账单
+			auth.DELETE("/trash/:id", controllers.PurgeEntry)         // 彻底删除账单
+			auth.DELETE("/trash", controllers.EmptyTrash)             // 清空回收站
+
+			auth.POST("/entries/:id/attachments", controllers.UploadAttachment) // 上传附件
+			auth.GET("/entries/:id/attachments", controllers.ListAttachments)   // 账单附件列表
+			auth.GET("/attachments/:id", controllers.DownloadAttachment)        // 下载附件
+			auth.DELETE("/attachments/:id", controllers.DeleteAttachment)       // 删除附件
+
+			auth.GET("/tags", controllers.ListTags)            // 账本中的标签
+			auth.PUT("/tags/:id", controllers.RenameTag)       // 重命名标签
+			auth.POST("/tags/:id/merge", controllers.MergeTag) // 合并标签
+			auth.DELETE("/tags/:id", controllers.DeleteTag)    // 删除标签
+
+			auth.GET("/reports/summary", controllers.GetSummary) // 收支汇总
+
+			auth.GET("/books/accounts", controllers.ListAccounts)               // 科目表
+			auth.POST("/books/accounts", controllers.CreateAccount)             // 新增科目
+			auth.PUT("/books/accounts/:id", controllers.UpdateAccount)          // 修改科目
+			auth.DELETE("/books/accounts/:id", controllers.DeleteAccount)       // 删除科目
+			auth.GET("/books/journal", controllers.ListJournal)                 // 记账凭证列表
+			auth.POST("/books/journal", controllers.CreateJournal)              // 录入记账凭证
+			auth.DELETE("/books/journal/:id", controllers.DeleteJournal)        // 删除记账凭证
+			auth.GET("/books/trial-balance", controllers.GetTrialBalance)       // 试算平衡表
+			auth.GET("/books/balance-sheet", controllers.GetBalanceSheet)       // 资产负债表
+			auth.GET("/books/income-statement", controllers.GetIncomeStatement) // 利润表
+			auth.GET("/entries/:id/postings", controllers.GetEntryPostings)     // 账单对应的分录
+
+			auth.POST("/reconciliations", controllers.StartReconciliation)             // 开始对账
+			auth.GET("/reconciliations", controllers.ListReconciliations)              // 对账记录
+			auth.GET("/reconciliations/:id", controllers.GetReconciliation)            // 对账详情
+			auth.POST("/reconciliations/:id/clear", controllers.ClearReconciliation)   // 勾选 / 取消勾选账单
+			auth.POST("/reconciliations/:id/finish", controllers.FinishReconciliation) // 完成对账并锁定账单
+			auth.DELETE("/reconciliations/:id", controllers.CancelReconciliation)      // 取消进行中的对账
+			auth.POST("/entries/:id/unlock", controllers.UnlockEntry)                  // 解锁已对账的账单
+
+			auth.POST("/goals", controllers.CreateGoal)                 // 创建储蓄目标
+			auth.GET("/goals", controllers.ListGoals)                   // 储蓄目标及进度
+			auth.GET("/goals/:id", controllers.GetGoal)                 // 储蓄目标详情
+			auth.PUT("/goals/:id", controllers.UpdateGoal)              // 修改储蓄目标
+			auth.DELETE("/goals/:id", controllers.DeleteGoal)           // 删除储蓄目标
+			auth.GET("/goals/:id/entries", controllers.ListGoalEntries) // 计入目标的账单
+
+			auth.POST("/net-worth/snapshots", controllers.TakeNetWorthSnapshot) // 立即生成净资产快照
+			auth.GET("/net-worth", controllers.GetNetWorth)                     // 净资产的资产、负债明细
+			auth.GET("/net-worth/history", controllers.GetNetWorthHistory)      // 净资产走势
+
+			auth.PUT("/me/base-currency", controllers.SetBaseCurrency)           // 修改本位币
+			auth.GET("/me/activity", controllers.ListActivity)                   // 我的操作记录
+			auth.POST("/exchange-rates", controllers.SaveExchangeRate)           // 录入汇率
+			auth.GET("/exchange-rates", controllers.ListExchangeRates)           // 查询汇率
+			auth.POST("/exchange-rates/import", controllers.ImportExchangeRates) // 导入汇率文件
+			auth.DELETE("/exchange-rates/:id", controllers.DeleteExchangeRate)   // 删除汇率
+
+			auth.POST("/loans", controllers.CreateLoan)                      // 创建借贷记录
+			auth.GET("/loans", controllers.ListLoans)                        // 借贷列表
+			auth.GET("/loans/:id", controllers.GetLoan)                      // 借贷详情
+			auth.POST("/loans/:id/repayments", controllers.AddLoanRepayment) // 登记还款
+			auth.DELETE("/loans/:id", controllers.DeleteLoan)                // 删除借贷记录
+
+			auth.POST("/ledgers", controllers.CreateLedger)                              // 创建账本
+			auth.GET("/ledgers", controllers.ListLedgers)                                // 我的账本
+			auth.PUT("/ledgers/:id", controllers.RenameLedger)                           // 重命名账本
+			auth.POST("/ledgers/:id/archive", controllers.ArchiveLedger)                 // 归档账本
+			auth.POST("/ledgers/:id/unarchive", controllers.UnarchiveLedger)             // 取消归档
+			auth.POST("/ledgers/:id/switch", controllers.SwitchLedger)                   // 切换当前账本
+			auth.POST("/ledgers/:id/close", controllers.ClosePeriod)                     // 结账
+			auth.POST("/ledgers/:id/reopen", controllers.ReopenPeriod)                   // 重新打开账期
+			auth.GET("/ledgers/:id/members", controllers.ListLedgerMembers)              // 账本成员
+			auth.PUT("/ledgers/:id/members/:user_id", controllers.UpdateLedgerMember)    // 修改成员角色
+			auth.DELETE("/ledgers/:id/members/:user_id", controllers.RemoveLedgerMember) // 移除成员 / 退出账本
+			auth.POST("/ledgers/:id/invitations", controllers.InviteLedgerMember)        // 邀请成员
+			auth.POST("/ledgers/:id/shared-expenses", controllers.CreateSharedExpense)   // 创建分摊支出
+			auth.GET("/ledgers/:id/shared-expenses", controllers.ListSharedExpenses)     // 分摊支出列表
+			auth.POST("/ledgers/:id/settlements", controllers.CreateSettlement)          // 记录还款
+			auth.GET("/ledgers/:id/balances", controllers.GetBalances)                   // 成员余额与结清方案
+			auth.DELETE("/shared-expenses/:id", controllers.DeleteSharedExpense)         // 删除分摊支出
+			auth.GET("/invitations", controllers.ListInvitations)                        // 待处理邀请
+			auth.POST("/invitations/:id/accept", controllers.AcceptInvitation)           // 接受邀请
+			auth.POST("/invitations/:id/decline", controllers.DeclineInvitation)         // 拒绝邀请
 		}
 	}
 	return r
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"go-ledger/models"
+	"go-ledger/utils"
 	"os"
 	"strings"
 	"time"
@@ -45,13 +46,15 @@ func (s *AIService) AnalyzeEntry(userInput string) (*models.LedgerEntry, error)
 	
 	字段说明:
 	- type: 1 (收入) 或 2 (支出)。如果不明确，默认为 2 (支出)。
-	- amount: 金额 (数字，单位元，默认0)。
+	- amount: 金额 (数字，默认0)。
+	- currency: 币种 (ISO 4217 三位代码，如 CNY、USD、JPY；用户没提到币种时留空)。
 	- category: 分类 (仅限: 餐饮, 交通, 购物, 居住, 娱乐, 医疗, 工资, 其他)。
 	- date: 日期 (格式 YYYY-MM-DD，根据用户描述如"昨天"结合当前日期计算)。
 	- remark: 备注 (简短描述，如果用户没说则留空)。
+	- tags: 标签数组 (用户明确提到的标签或用途，如 "出差"、"可报销"；没有则为空数组)。
 	
 	示例输出:
-	{"type": 2, "amount": 15.5, "category": "餐饮", "date": "2023-10-01", "remark": "午饭"}
+	{"type": 2, "amount": 15.5, "currency": "", "category": "餐饮", "date": "2023-10-01", "remark": "午饭", "tags": []}
 	`, dateInfo)
 
 	// 4. 发起请求
@@ -81,11 +84,13 @@ func (s *AIService) AnalyzeEntry(userInput string) (*models.LedgerEntry, error)
 
 	// 定义临时结构体用于解析 JSON (因为 LedgerEntry 包含 gorm.Model 等复杂字段，这里用个简单的 DTO 接收)
 	type AIResponse struct {
-		Type     int     `json:"type"`
-		Amount   float64 `json:"amount"`
-		Category string  `json:"category"`
-		Date     string  `json:"date"`
-		Remark   string  `json:"remark"`
+		Type     int      `json:"type"`
+		Amount   float64  `json:"amount"`
+		Currency string   `json:"currency"`
+		Category string   `json:"category"`
+		Date     string   `json:"date"`
+		Remark   string   `json:"remark"`
+		Tags     []string `json:"tags"`
 	}
 
 	var aiResp AIResponse
@@ -100,13 +105,23 @@ func (s *AIService) AnalyzeEntry(userInput string) (*models.LedgerEntry, error)
 		parsedDate = time.Now() // 解析失败则默认今天
 	}
 
+	// 币种无法识别时留空，保存时使用本位币
+	currency, ok := utils.NormalizeCurrency(aiResp.Currency)
+	if !ok {
+		currency = ""
+	}
+
 	entry := &models.LedgerEntry{
 		Type:     aiResp.Type,
 		Amount:   aiResp.Amount,
+		Currency: currency,
 		Category: aiResp.Category,
 		Date:     parsedDate,
 		Remark:   aiResp.Remark,
 	}
+	for _, name := range aiResp.Tags {
+		entry.Tags = append(entry.Tags, models.Tag{Name: name})
+	}
 
 	return entry, nil
 }
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/storage"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAttachmentNotFound 附件不存在或无权访问
+	ErrAttachmentNotFound = errors.New("附件不存在或无权访问")
+	// ErrAttachmentTooLarge 附件超过大小限制
+	ErrAttachmentTooLarge = errors.New("附件超过大小限制")
+	// ErrAttachmentType 附件类型不在允许范围内
+	ErrAttachmentType = errors.New("不支持的附件类型")
+	// ErrAttachmentLimit 账单附件数量已达上限
+	ErrAttachmentLimit = errors.New("账单附件数量已达上限")
+)
+
+// 附件限制默认值，可在 config.yaml 的 attachment 下覆盖
+const (
+	defaultAttachmentMaxSize     = 10 << 20 // 10MB
+	defaultAttachmentMaxPerEntry = 10
+	attachmentFileNameMaxRunes   = 200
+)
+
+var defaultAttachmentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"}
+
+type AttachmentService struct{}
+
+// AttachmentMaxSize 单个附件的大小上限（字节）
+func AttachmentMaxSize() int64 {
+	if size := viper.GetInt64("attachment.max_size"); size > 0 {
+		return size
+	}
+	return defaultAttachmentMaxSize
+}
+
+// Upload 为账单上传附件，权限要求与删除账单一致
+// 文件类型根据内容判断，不信任客户端声明的 Content-Type
+func (s *AttachmentService) Upload(entryID, userID uint, fileName string, size int64, r io.Reader) (*models.Attachment, error) {
+	entry, err := writableEntry(entryID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if size > AttachmentMaxSize() {
+		return nil, ErrAttachmentTooLarge
+	}
+	// 先检查一次，已达上限时不必上传文件；写入时在事务中锁住账单再检查一次
+	if err := checkAttachmentLimit(config.DB, entry.ID); err != nil {
+		return nil, err
+	}
+
+	// 读取文件头判断类型
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	if !attachmentTypeAllowed(contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentType, contentType)
+	}
+
+	key, err := attachmentKey(entry.LedgerID, entry.ID, fileName)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if err := storage.Default.Put(ctx, key, io.MultiReader(bytes.NewReader(head), r), size, contentType); err != nil {
+		return nil, err
+	}
+
+	attachment := models.Attachment{
+		EntryID:     entry.ID,
+		LedgerID:    entry.LedgerID,
+		UserID:      userID,
+		FileName:    cleanFileName(fileName),
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  key,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁住账单，同一账单的并发上传依次计数，不会超过上限
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.LedgerEntry{}, entry.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEntryNotFound
+			}
+			return err
+		}
+		if err := checkAttachmentLimit(tx, entry.ID); err != nil {
+			return err
+		}
+		return tx.Create(&attachment).Error
+	})
+	if err != nil {
+		removeFiles([]string{key})
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// checkAttachmentLimit 账单的附件数量已达到 attachment.max_per_entry 时返回 ErrAttachmentLimit
+func checkAttachmentLimit(db *gorm.DB, entryID uint) error {
+	var count int64
+	if err := db.Model(&models.Attachment{}).Where("entry_id = ?", entryID).Count(&count).Error; err != nil {
+		return err
+	}
+	maxPerEntry := viper.GetInt64("attachment.max_per_entry")
+	if maxPerEntry <= 0 {
+		maxPerEntry = defaultAttachmentMaxPerEntry
+	}
+	if count >= maxPerEntry {
+		return ErrAttachmentLimit
+	}
+	return nil
+}
+
+// List 查询账单的附件
+func (s *AttachmentService) List(entryID, userID uint) ([]models.Attachment, error) {
+	var entry models.LedgerEntry
+	if err := visibleEntries(config.DB, userID).Where("id = ?", entryID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	var attachments []models.Attachment
+	err := config.DB.Where("entry_id = ?", entry.ID).Order("id").Find(&attachments).Error
+	return attachments, err
+}
+
+// Open 打开附件内容用于下载，账本成员均可下载，调用方负责关闭
+func (s *AttachmentService) Open(id, userID uint) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := findAttachment(id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := storage.Default.Get(context.Background(), attachment.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, body, nil
+}
+
+// Delete 删除附件，权限要求与删除账单一致
+func (s *AttachmentService) Delete(id, userID uint) error {
+	attachment, err := findAttachment(id, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := writableEntry(attachment.EntryID, userID); err != nil {
+		return err
+	}
+	if err := config.DB.Delete(attachment).Error; err != nil {
+		return err
+	}
+	removeFiles([]string{attachment.StorageKey})
+	return nil
+}
+
+// writableEntry 查找当前用户可见的账单，并要求是所属账本的编辑者且账本未归档
+func writableEntry(entryID, userID uint) (*models.LedgerEntry, error) {
+	var entry models.LedgerEntry
+	if err := visibleEntries(config.DB, userID).Where("id = ?", entryID).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	if _, err := writableLedger(entry.LedgerID, userID); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// findAttachment 查找当前用户所在账本中、账单未删除的附件
+func findAttachment(id, userID uint) (*models.Attachment, error) {
+	var attachment models.Attachment
+	err := config.DB.Where("id = ? AND entry_id IN (?)", id,
+		visibleEntries(config.DB.Model(&models.LedgerEntry{}).Select("id"), userID)).
+		First(&attachment).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// deleteEntryAttachments 在事务中删除账单的附件记录，返回需要在提交后删除的文件 key
+func deleteEntryAttachments(tx *gorm.DB, entryIDs []uint) ([]string, error) {
+	if len(entryIDs) == 0 {
+		return nil, nil
+	}
+	var keys []string
+	if err := tx.Model(&models.Attachment{}).Where("entry_id IN ?", entryIDs).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("entry_id IN ?", entryIDs).Delete(&models.Attachment{}).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// removeFiles 删除存储中的文件，记录已删除，失败只记录日志
+func removeFiles(keys []string) {
+	for _, key := range keys {
+		if err := storage.Default.Delete(context.Background(), key); err != nil {
+			log.Printf("删除附件文件失败 %s: %v", key, err)
+		}
+	}
+}
+
+func attachmentTypeAllowed(contentType string) bool {
+	allowed := viper.GetStringSlice("attachment.allowed_types")
+	if len(allowed) == 0 {
+		allowed = defaultAttachmentTypes
+	}
+	for _, t := range allowed {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentKey 生成存储 key：ledgers/<账本>/entries/<账单>/<随机串><扩展名>
+// 不使用原始文件名，避免路径穿越和重名
+func attachmentKey(ledgerID, entryID uint, fileName string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	// 扩展名只保留字母和数字
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if len(ext) > 10 || strings.TrimLeft(ext, ".abcdefghijklmnopqrstuvwxyz0123456789") != "" {
+		ext = ""
+	}
+	return fmt.Sprintf("ledgers/%d/entries/%d/%s%s", ledgerID, entryID, hex.EncodeToString(buf), ext), nil
+}
+
+// cleanFileName 只保留文件名部分并限制长度，用于下载时展示
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	if !utf8.ValidString(name) || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if runes := []rune(name); len(runes) > attachmentFileNameMaxRunes {
+		name = string(runes[len(runes)-attachmentFileNameMaxRunes:])
+	}
+	return name
+}
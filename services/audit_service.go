@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"go-ledger/config"
+	"go-ledger/models"
+
+	"gorm.io/gorm"
+)
+
+type AuditService struct{}
+
+// RequestMeta 写入审计日志的请求信息，由中间件放入请求的 context：
+// 请求 ID 和客户端 IP 来自 RequestIDMiddleware，操作人来自 JWT 中间件
+type RequestMeta struct {
+	RequestID string
+	IP        string
+	UserID    uint
+}
+
+type requestMetaKey struct{}
+
+// WithRequestMeta 把请求信息放入 context，Service 在以该 context 开启的事务中写审计日志时读取
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// WithActor 在 context 的请求信息中记录操作人
+func WithActor(ctx context.Context, userID uint) context.Context {
+	meta := requestMetaFrom(ctx)
+	meta.UserID = userID
+	return WithRequestMeta(ctx, meta)
+}
+
+func requestMetaFrom(ctx context.Context) RequestMeta {
+	if ctx == nil {
+		return RequestMeta{}
+	}
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+// writeAudit 追加审计日志，请求 ID、IP 和操作人（未指定 UserID 时）取自 db 的 context
+// 传入事务时与业务修改一起提交或回滚
+func writeAudit(db *gorm.DB, logs ...models.AuditLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	meta := requestMetaFrom(db.Statement.Context)
+	for i := range logs {
+		if logs[i].UserID == 0 {
+			logs[i].UserID = meta.UserID
+		}
+		if logs[i].IP == "" {
+			logs[i].IP = meta.IP
+		}
+		logs[i].RequestID = meta.RequestID
+	}
+	return db.Create(&logs).Error
+}
+
+// entryAudit 生成一条账单审计日志，before、after 为变更前后的账单（含标签和拆分明细），可以为 nil
+func entryAudit(action string, before, after *models.LedgerEntry) (models.AuditLog, error) {
+	log := models.AuditLog{Action: action}
+	var err error
+	if before != nil {
+		log.LedgerID, log.EntryID = before.LedgerID, before.ID
+		if log.Before, err = json.Marshal(before); err != nil {
+			return log, err
+		}
+	}
+	if after != nil {
+		log.LedgerID, log.EntryID = after.LedgerID, after.ID
+		if log.After, err = json.Marshal(after); err != nil {
+			return log, err
+		}
+	}
+	return log, nil
+}
+
+// auditEntry 在事务中记录一条账单变更
+func auditEntry(tx *gorm.DB, action string, before, after *models.LedgerEntry) error {
+	log, err := entryAudit(action, before, after)
+	if err != nil {
+		return err
+	}
+	return writeAudit(tx, log)
+}
+
+// auditRemovedEntries 记录一批账单的删除或彻底删除，entries 为删除前的快照
+func auditRemovedEntries(tx *gorm.DB, action string, entries []models.LedgerEntry) error {
+	logs := make([]models.AuditLog, 0, len(entries))
+	for i := range entries {
+		log, err := entryAudit(action, &entries[i], nil)
+		if err != nil {
+			return err
+		}
+		logs = append(logs, log)
+	}
+	return writeAudit(tx, logs...)
+}
+
+// auditChangedEntries 记录一批账单的状态变更（如对账勾选、锁定），before 为变更前的快照，变更后的快照在这里重新读取
+func auditChangedEntries(tx *gorm.DB, action string, before []models.LedgerEntry) error {
+	if len(before) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(before))
+	for _, entry := range before {
+		ids = append(ids, entry.ID)
+	}
+	after, err := entrySnapshots(tx, ids)
+	if err != nil {
+		return err
+	}
+	afterByID := make(map[uint]*models.LedgerEntry, len(after))
+	for i := range after {
+		afterByID[after[i].ID] = &after[i]
+	}
+	logs := make([]models.AuditLog, 0, len(before))
+	for i := range before {
+		log, err := entryAudit(action, &before[i], afterByID[before[i].ID])
+		if err != nil {
+			return err
+		}
+		logs = append(logs, log)
+	}
+	return writeAudit(tx, logs...)
+}
+
+// entrySnapshots 读取账单（包括已删除的）及其标签和拆分明细，用作审计快照
+func entrySnapshots(tx *gorm.DB, ids []uint) ([]models.LedgerEntry, error) {
+	var entries []models.LedgerEntry
+	err := tx.Unscoped().Preload("Tags").Preload("Splits").Where("id IN ?", ids).Order("id").Find(&entries).Error
+	return entries, err
+}
+
+// entrySnapshot 读取单条账单的审计快照
+func entrySnapshot(tx *gorm.DB, id uint) (*models.LedgerEntry, error) {
+	entries, err := entrySnapshots(tx, []uint{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	return &entries[0], nil
+}
+
+// EntryHistory 分页查询账单的变更历史，最近的在前
+// 要求当前用户是账单所属账本的成员；账单被彻底删除后仍可查询
+func (s *AuditService) EntryHistory(entryID, userID uint, page, pageSize int) ([]models.AuditLog, int64, error) {
+	query := config.DB.Model(&models.AuditLog{}).
+		Where("entry_id = ? AND ledger_id IN (?)", entryID, memberLedgerIDs(userID))
+	logs, total, err := pageAuditLogs(query, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, ErrEntryNotFound
+	}
+	return logs, total, nil
+}
+
+// ListActivity 分页查询用户自己的操作记录（账单变更、登录等），最近的在前；action 不为空时只返回该动作
+func (s *AuditService) ListActivity(userID uint, action string, page, pageSize int) ([]models.AuditLog, int64, error) {
+	query := config.DB.Model(&models.AuditLog{}).Where("user_id = ?", userID)
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	return pageAuditLogs(query, page, pageSize)
+}
+
+func pageAuditLogs(query *gorm.DB, page, pageSize int) ([]models.AuditLog, int64, error) {
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []models.AuditLog
+	if err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"go-ledger/config"
 	"go-ledger/models"
 	"go-ledger/utils"
@@ -14,50 +16,92 @@ type AuthService struct{}
 
 // Register 用户注册业务逻辑
 func (s *AuthService) Register(username, password string) (*models.User, error) {
-	// 1. 检查用户名是否存在
+	// 1. 校验用户名和密码策略
+	fields := utils.FieldErrors{}
+	if msg := utils.ValidateUsername(username); msg != "" {
+		fields["username"] = msg
+	}
+	if msg := utils.ValidatePassword(password, username); msg != "" {
+		fields["password"] = msg
+	}
+	if len(fields) > 0 {
+		return nil, fields
+	}
+
+	// 2. 检查用户名是否存在（不区分大小写）
 	var existingUser models.User
-	if err := config.DB.Where("username = ?", username).First(&existingUser).Error; err == nil {
-		return nil, errors.New("用户名已存在")
+	if err := config.DB.Where("LOWER(username) = LOWER(?)", username).First(&existingUser).Error; err == nil {
+		return nil, utils.FieldErrors{"username": "用户名已存在"}
 	} else if err != gorm.ErrRecordNotFound {
 		return nil, err // 数据库错误
 	}
 
-	// 2. 密码加密
+	// 3. 密码加密
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, errors.New("密码加密失败")
 	}
 
-	// 3. 创建用户
+	// 4. 创建用户，同时创建默认账本并设为当前账本
 	user := models.User{
 		Username: username,
 		Password: string(hashedPassword),
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		ledger, err := createLedger(tx, user.ID, models.DefaultLedgerName)
+		if err != nil {
+			return err
+		}
+		user.CurrentLedgerID = ledger.ID
+		return tx.Model(&user).Update("current_ledger_id", ledger.ID).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
+// ErrLoginLocked 登录失败次数过多，暂时禁止登录
+// 无论用户名是否存在都返回同样的错误，避免泄露账号是否存在
+var ErrLoginLocked = errors.New("登录尝试过于频繁，请稍后再试")
+
+// dummyHash 用户名不存在时用于比对的假密码哈希，使两种情况的耗时一致
+const dummyHash = "$2a$10$yjsLjVbUQkanKDCO3hh77.cFHGu01TeAvg2.SM7PRymcTuzwGJ4TG"
+
 // Login 用户登录业务逻辑
-func (s *AuthService) Login(username, password string) (string, error) {
-	// 1. 查找用户
+// 登录成功和失败都会写入审计日志，请求 ID 取自 ctx
+func (s *AuthService) Login(ctx context.Context, username, password, ip string) (string, error) {
+	// 1. 检查用户名 / IP 是否被锁定
+	if limiter.locked(username, ip) {
+		return "", ErrLoginLocked
+	}
+
+	// 2. 查找用户
 	var user models.User
 	if err := config.DB.Where("username = ?", username).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return "", errors.New("用户名或密码错误")
+		if err != gorm.ErrRecordNotFound {
+			return "", err
 		}
-		return "", err
+		// 用户不存在也做一次哈希比对并计入失败次数
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		s.loginFailed(ctx, 0, username, ip)
+		return "", errors.New("用户名或密码错误")
 	}
 
-	// 2. 验证密码
+	// 3. 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		s.loginFailed(ctx, user.ID, username, ip)
 		return "", errors.New("用户名或密码错误")
 	}
+	limiter.succeed(username)
+	s.audit(ctx, models.AuditLog{UserID: user.ID, Username: user.Username, Action: models.AuditActionLogin, IP: ip})
 
-	// 3. 生成 Token
+	// 4. 生成 Token
 	token, err := utils.GenerateToken(user.ID)
 	if err != nil {
 		return "", errors.New("Token生成失败")
@@ -65,3 +109,24 @@ func (s *AuthService) Login(username, password string) (string, error) {
 
 	return token, nil
 }
+
+// loginFailed 记录一次登录失败并写入审计日志，如果触发了锁定再记录一条锁定日志
+func (s *AuthService) loginFailed(ctx context.Context, userID uint, username, ip string) {
+	s.audit(ctx, models.AuditLog{UserID: userID, Username: username, Action: models.AuditActionLoginFailed, IP: ip})
+	for _, e := range limiter.fail(username, ip) {
+		s.audit(ctx, models.AuditLog{
+			UserID:   userID,
+			Username: username,
+			Action:   models.AuditActionLoginLocked,
+			IP:       ip,
+			Detail:   fmt.Sprintf("按%s锁定：连续失败 %d 次，锁定 %s", e.Scope, e.Failures, e.Duration),
+		})
+	}
+}
+
+// audit 写入登录相关的审计日志，失败只打印警告，不影响登录结果
+func (s *AuthService) audit(ctx context.Context, log models.AuditLog) {
+	if err := writeAudit(config.DB.WithContext(ctx), log); err != nil {
+		fmt.Printf("Warning: 写入审计日志失败: %v\n", err)
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-ledger/config"
+	"go-ledger/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrBatchAborted 整体模式下有失败项，所有操作已回滚
+var ErrBatchAborted = errors.New("批量操作中有失败项，已全部回滚")
+
+// BatchItem 批量操作的一项输入
+// Err 不为空表示该项在解析或参数校验阶段已经失败，不会执行
+type BatchItem struct {
+	ID      uint                // 修改、删除时的账单 ID
+	Version *uint64             // 修改、删除时客户端看到的账单版本（sync_seq），与当前版本不一致时该项返回 ErrEntryModified
+	Entry   *models.LedgerEntry // 新增、修改时的账单内容
+	Err     error
+}
+
+// BatchResult 批量操作中一项的结果，Index 对应请求中的下标
+type BatchResult struct {
+	Index int
+	Entry *models.LedgerEntry // 新增、修改成功后的账单
+	Err   error
+}
+
+// BatchCreateEntries 批量新增账单，每项的 UserID 由调用方填好
+// atomic 为 true 时在一个事务中执行，任何一项失败都全部回滚并返回 ErrBatchAborted；
+// 否则每项独立执行，失败项不影响其他项
+func (s *EntryService) BatchCreateEntries(ctx context.Context, items []BatchItem, atomic bool) ([]BatchResult, error) {
+	return runBatch(ctx, items, atomic, func(tx *gorm.DB, item BatchItem) (*models.LedgerEntry, error) {
+		if err := addEntry(tx, item.Entry); err != nil {
+			return nil, err
+		}
+		return item.Entry, nil
+	})
+}
+
+// BatchUpdateEntries 批量修改账单，规则和版本校验同 UpdateEntry，事务模式同 BatchCreateEntries
+func (s *EntryService) BatchUpdateEntries(ctx context.Context, userID uint, items []BatchItem, atomic bool) ([]BatchResult, error) {
+	return runBatch(ctx, items, atomic, func(tx *gorm.DB, item BatchItem) (*models.LedgerEntry, error) {
+		if err := checkEntryVersion(tx, item.ID, userID, item.Version); err != nil {
+			return nil, err
+		}
+		return editEntry(tx, item.ID, userID, item.Entry)
+	})
+}
+
+// BatchDeleteEntries 批量删除账单（移入回收站），规则和版本校验同 DeleteEntry，事务模式同 BatchCreateEntries
+func (s *EntryService) BatchDeleteEntries(ctx context.Context, userID uint, items []BatchItem, atomic bool) ([]BatchResult, error) {
+	return runBatch(ctx, items, atomic, func(tx *gorm.DB, item BatchItem) (*models.LedgerEntry, error) {
+		if err := checkEntryVersion(tx, item.ID, userID, item.Version); err != nil {
+			return nil, err
+		}
+		return nil, removeEntry(tx, item.ID, userID)
+	})
+}
+
+// runBatch 按事务模式逐项执行批量操作
+// 整体模式下先检查所有项的输入错误，有错误时不访问数据库，返回全部出错项；
+// 执行中遇到失败立即回滚，只返回失败的那一项
+func runBatch(ctx context.Context, items []BatchItem, atomic bool, op func(tx *gorm.DB, item BatchItem) (*models.LedgerEntry, error)) ([]BatchResult, error) {
+	results := make([]BatchResult, 0, len(items))
+	if !atomic {
+		for i, item := range items {
+			result := BatchResult{Index: i, Err: item.Err}
+			if result.Err == nil {
+				result.Err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+					var err error
+					result.Entry, err = op(tx, item)
+					return err
+				})
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	}
+
+	for i, item := range items {
+		if item.Err != nil {
+			results = append(results, BatchResult{Index: i, Err: item.Err})
+		}
+	}
+	if len(results) > 0 {
+		return results, ErrBatchAborted
+	}
+
+	var failed *BatchResult
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, item := range items {
+			entry, err := op(tx, item)
+			if err != nil {
+				failed = &BatchResult{Index: i, Err: err}
+				return err
+			}
+			results = append(results, BatchResult{Index: i, Entry: entry})
+		}
+		return nil
+	})
+	if failed != nil {
+		return []BatchResult{*failed}, ErrBatchAborted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,512 @@
+package services
+
+import (
+	"errors"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// bookAccount 统计用的科目，临时科目（科目表中没有同名科目）的 ID 和 Code 为空
+type bookAccount struct {
+	ID   uint
+	Code string
+	Name string
+	Type string
+}
+
+func bookAccountOf(account models.Account) bookAccount {
+	return bookAccount{ID: account.ID, Code: account.Code, Name: account.Name, Type: account.Type}
+}
+
+// accountMapper 按名称把账单的资金账户、分类对应到科目
+type accountMapper map[string]models.Account
+
+func newAccountMapper(accounts []models.Account) accountMapper {
+	mapper := make(accountMapper, len(accounts))
+	for _, account := range accounts {
+		mapper[account.Name] = account
+	}
+	return mapper
+}
+
+// account 返回指定类型的同名科目，没有时返回临时科目
+func (m accountMapper) account(name, accountType string) bookAccount {
+	if account, ok := m[name]; ok && account.Type == accountType {
+		return bookAccountOf(account)
+	}
+	return bookAccount{Name: name, Type: accountType}
+}
+
+// money 返回资金账户对应的科目：同名的资产或负债（如信用卡）科目，没有时作为临时资产科目
+// 没有填写资金账户的账单记入"现金"
+func (m accountMapper) money(name string) bookAccount {
+	if name == "" {
+		name = bookCashAccount
+	}
+	if account, ok := m[name]; ok && (account.Type == models.AccountTypeAsset || account.Type == models.AccountTypeLiability) {
+		return bookAccountOf(account)
+	}
+	return bookAccount{Name: name, Type: models.AccountTypeAsset}
+}
+
+// whereEntryAccount 限定为资金账户对应指定科目的账单，规则与 money 一致：没有填写资金账户的账单属于"现金"
+func whereEntryAccount(query *gorm.DB, name string) *gorm.DB {
+	if name == bookCashAccount {
+		return query.Where("account IN ?", []string{"", name})
+	}
+	return query.Where("account = ?", name)
+}
+
+// entryAccounts 返回账单（或拆分行）的借方、贷方科目
+// 支出：借 费用 / 贷 资金账户；收入：借 资金账户 / 贷 收入
+// 借贷产生的账单以应收、应付借款代替收支科目，例如借出为 借 应收借款 / 贷 资金账户
+func (m accountMapper) entryAccounts(entryType int, category, account string, loan bool) (debit, credit bookAccount) {
+	money := m.money(account)
+	var counter bookAccount
+	switch {
+	case loan && (category == loanCategoryBorrow || category == loanCategoryRepay):
+		counter = m.account(bookPayableAccount, models.AccountTypeLiability)
+	case loan:
+		counter = m.account(bookReceivableAccount, models.AccountTypeAsset)
+	case entryType == models.EntryTypeIncome:
+		counter = m.account(category, models.AccountTypeIncome)
+	default:
+		counter = m.account(category, models.AccountTypeExpense)
+	}
+	if entryType == models.EntryTypeIncome {
+		return money, counter
+	}
+	return counter, money
+}
+
+// accountTotals 科目的借贷发生额（分），opening 为报表开始日期之前的部分
+type accountTotals struct {
+	openingDebit, openingCredit int64
+	debit, credit               int64
+}
+
+// bookTotals 账本所有科目的发生额
+type bookTotals struct {
+	base     string
+	from     string // 早于该日期的发生额计入期初，为空时全部计入本期
+	accounts map[bookAccount]*accountTotals
+}
+
+func (b *bookTotals) post(account bookAccount, date time.Time, debit, credit int64) {
+	totals := b.accounts[account]
+	if totals == nil {
+		totals = &accountTotals{}
+		b.accounts[account] = totals
+	}
+	if b.from != "" && date.Format(utils.DateLayout) < b.from {
+		totals.openingDebit += debit
+		totals.openingCredit += credit
+		return
+	}
+	totals.debit += debit
+	totals.credit += credit
+}
+
+// balances 返回指定类型科目的余额，按类型、编码、名称排序，临时科目排在同类正式科目之后
+func (b *bookTotals) balances(types ...string) []models.AccountBalance {
+	order := make(map[string]int, len(types))
+	for i, t := range types {
+		order[t] = i
+	}
+	accounts := make([]bookAccount, 0, len(b.accounts))
+	for account := range b.accounts {
+		if _, ok := order[account.Type]; ok {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		a, c := accounts[i], accounts[j]
+		if a.Type != c.Type {
+			return order[a.Type] < order[c.Type]
+		}
+		if (a.Code == "") != (c.Code == "") {
+			return a.Code != ""
+		}
+		if a.Code != c.Code {
+			return a.Code < c.Code
+		}
+		return a.Name < c.Name
+	})
+
+	balances := make([]models.AccountBalance, 0, len(accounts))
+	for _, account := range accounts {
+		totals := b.accounts[account]
+		// 资产、费用的正常余额在借方，负债、权益、收入在贷方
+		sign := int64(-1)
+		if account.Type == models.AccountTypeAsset || account.Type == models.AccountTypeExpense {
+			sign = 1
+		}
+		opening := sign * (totals.openingDebit - totals.openingCredit)
+		balances = append(balances, models.AccountBalance{
+			AccountID: account.ID,
+			Code:      account.Code,
+			Name:      account.Name,
+			Type:      account.Type,
+			Opening:   utils.FromCents(opening),
+			Debit:     utils.FromCents(totals.debit),
+			Credit:    utils.FromCents(totals.credit),
+			Closing:   utils.FromCents(opening + sign*(totals.debit-totals.credit)),
+		})
+	}
+	return balances
+}
+
+// bookRow 账单换算分录的中间结果：同类型、分类、资金账户、币种在某一天的合计
+type bookRow struct {
+	Type     int
+	Category string
+	Account  string
+	Loan     bool
+	Currency string
+	Date     time.Time
+	Amount   float64
+}
+
+// journalRow 手工凭证的一条分录及凭证的币种、日期
+type journalRow struct {
+	TransactionID uint
+	AccountID     uint
+	Currency      string
+	Date          time.Time
+	Debit         float64
+	Credit        float64
+}
+
+// collectBook 汇总账本中账单换算出的分录和手工凭证，金额按当天汇率换算为用户的本位币
+// withOpening 为 true 时读取 dates.from 之前的全部记录作为期初，否则只统计区间内的记录
+func collectBook(userID, ledgerID uint, dates dateRange, withOpening bool) (*bookTotals, error) {
+	converter, err := newRateConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+	return collectLedgerBook(converter, ledgerID, dates, withOpening, "")
+}
+
+// collectLedgerBook 同 collectBook，金额换算为 converter 的本位币
+// currency 不为空时只统计该币种的账单和凭证，converter 的本位币与之相同时不需要汇率
+func collectLedgerBook(converter *rateConverter, ledgerID uint, dates dateRange, withOpening bool, currency string) (*bookTotals, error) {
+	accounts, err := ledgerAccounts(config.DB, ledgerID)
+	if err != nil {
+		return nil, err
+	}
+	book := &bookTotals{base: converter.base, accounts: make(map[bookAccount]*accountTotals)}
+	for _, account := range accounts {
+		book.accounts[bookAccountOf(account)] = &accountTotals{}
+	}
+	query := dates
+	if withOpening {
+		book.from = dates.from
+		query.from = ""
+	}
+
+	// 与收支汇总相同：按天分组后再换算，借贷双方使用同一个换算结果，保证平衡
+	bookEntries := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("ledger_entries.deleted_at IS NULL AND ledger_entries.ledger_id = ?", ledgerID)
+		if query.from != "" {
+			db = db.Where("ledger_entries.date >= ?", query.from)
+		}
+		if query.before != "" {
+			db = db.Where("ledger_entries.date < ?", query.before)
+		}
+		if currency != "" {
+			db = db.Where("ledger_entries.currency = ?", currency)
+		}
+		return db
+	}
+	const loanColumn = "ledger_entries.loan_id IS NOT NULL"
+	var rows, splitRows []bookRow
+	err = bookEntries(config.DB.Table("ledger_entries")).
+		Where("NOT EXISTS (SELECT 1 FROM entry_splits WHERE entry_splits.entry_id = ledger_entries.id)").
+		Select("ledger_entries.type, ledger_entries.category, ledger_entries.account, " + loanColumn + " AS loan, " +
+			"ledger_entries.currency, ledger_entries.date, SUM(ledger_entries.amount) AS amount").
+		Group("ledger_entries.type, ledger_entries.category, ledger_entries.account, " + loanColumn + ", " +
+			"ledger_entries.currency, ledger_entries.date").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	err = bookEntries(config.DB.Table("entry_splits").
+		Joins("JOIN ledger_entries ON ledger_entries.id = entry_splits.entry_id")).
+		Select("ledger_entries.type, entry_splits.category, ledger_entries.account, " + loanColumn + " AS loan, " +
+			"ledger_entries.currency, ledger_entries.date, SUM(entry_splits.amount) AS amount").
+		Group("ledger_entries.type, entry_splits.category, ledger_entries.account, " + loanColumn + ", " +
+			"ledger_entries.currency, ledger_entries.date").
+		Scan(&splitRows).Error
+	if err != nil {
+		return nil, err
+	}
+	mapper := newAccountMapper(accounts)
+	for _, row := range append(rows, splitRows...) {
+		converted, err := converter.convert(row.Amount, row.Currency, row.Date)
+		if err != nil {
+			return nil, err
+		}
+		cents := utils.ToCents(converted)
+		debit, credit := mapper.entryAccounts(row.Type, row.Category, row.Account, row.Loan)
+		book.post(debit, row.Date, cents, 0)
+		book.post(credit, row.Date, 0, cents)
+	}
+
+	journal := config.DB.Table("postings").
+		Joins("JOIN journal_transactions ON journal_transactions.id = postings.transaction_id").
+		Where("journal_transactions.ledger_id = ?", ledgerID)
+	if query.from != "" {
+		journal = journal.Where("journal_transactions.date >= ?", query.from)
+	}
+	if query.before != "" {
+		journal = journal.Where("journal_transactions.date < ?", query.before)
+	}
+	if currency != "" {
+		journal = journal.Where("journal_transactions.currency = ?", currency)
+	}
+	var lines []journalRow
+	err = journal.Select("postings.transaction_id, postings.account_id, journal_transactions.currency, " +
+		"journal_transactions.date, postings.debit, postings.credit").
+		Order("postings.transaction_id, postings.id").
+		Scan(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]bookAccount, len(accounts))
+	for _, account := range accounts {
+		byID[account.ID] = bookAccountOf(account)
+	}
+	for start := 0; start < len(lines); {
+		end := start
+		for end < len(lines) && lines[end].TransactionID == lines[start].TransactionID {
+			end++
+		}
+		if err := postJournal(book, converter, byID, lines[start:end]); err != nil {
+			return nil, err
+		}
+		start = end
+	}
+	return book, nil
+}
+
+// postJournal 记入一张凭证的分录，逐行换算后的尾差调整到最后一行，保证凭证借贷平衡
+func postJournal(book *bookTotals, converter *rateConverter, accounts map[uint]bookAccount, lines []journalRow) error {
+	debits := make([]int64, len(lines))
+	credits := make([]int64, len(lines))
+	var diff int64
+	for i, line := range lines {
+		debit, err := converter.convert(line.Debit, line.Currency, line.Date)
+		if err != nil {
+			return err
+		}
+		credit, err := converter.convert(line.Credit, line.Currency, line.Date)
+		if err != nil {
+			return err
+		}
+		debits[i], credits[i] = utils.ToCents(debit), utils.ToCents(credit)
+		diff += debits[i] - credits[i]
+	}
+	last := len(lines) - 1
+	if debits[last] > 0 {
+		debits[last] -= diff
+	} else {
+		credits[last] += diff
+	}
+	for i, line := range lines {
+		book.post(accounts[line.AccountID], line.Date, debits[i], credits[i])
+	}
+	return nil
+}
+
+// bookLedger 解析报表参数，返回要统计的账本和日期区间
+func bookLedger(userID uint, filter models.ReportFilter) (uint, dateRange, error) {
+	ledger, err := resolveLedger(filter.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return 0, dateRange{}, err
+	}
+	dates, err := parseReportDates(filter)
+	if err != nil {
+		return 0, dateRange{}, err
+	}
+	return ledger.ID, dates, nil
+}
+
+// TrialBalance 试算平衡表：各科目期初余额、本期借贷发生额和期末余额
+func (s *BookService) TrialBalance(userID uint, filter models.ReportFilter) (*models.TrialBalance, error) {
+	ledgerID, dates, err := bookLedger(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	book, err := collectBook(userID, ledgerID, dates, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.TrialBalance{
+		BaseCurrency: book.base,
+		StartDate:    filter.StartDate,
+		EndDate:      filter.EndDate,
+		Accounts: book.balances(models.AccountTypeAsset, models.AccountTypeLiability, models.AccountTypeEquity,
+			models.AccountTypeIncome, models.AccountTypeExpense),
+	}
+	var debit, credit int64
+	for _, totals := range book.accounts {
+		debit += totals.debit
+		credit += totals.credit
+	}
+	report.TotalDebit = utils.FromCents(debit)
+	report.TotalCredit = utils.FromCents(credit)
+	report.Balanced = debit == credit
+	return report, nil
+}
+
+// BalanceSheet 资产负债表：截至 end_date（不传则统计全部记录）的科目余额，start_date 不参与计算
+func (s *BookService) BalanceSheet(userID uint, filter models.ReportFilter) (*models.BalanceSheet, error) {
+	ledgerID, dates, err := bookLedger(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	dates.from = ""
+	book, err := collectBook(userID, ledgerID, dates, false)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.BalanceSheet{
+		BaseCurrency: book.base,
+		EndDate:      filter.EndDate,
+		Assets:       book.balances(models.AccountTypeAsset),
+		Liabilities:  book.balances(models.AccountTypeLiability),
+		Equity:       book.balances(models.AccountTypeEquity),
+	}
+	var assets, liabilities, equity, retained int64
+	for _, balance := range report.Assets {
+		assets += utils.ToCents(balance.Closing)
+	}
+	for _, balance := range report.Liabilities {
+		liabilities += utils.ToCents(balance.Closing)
+	}
+	for _, balance := range report.Equity {
+		equity += utils.ToCents(balance.Closing)
+	}
+	for _, balance := range book.balances(models.AccountTypeIncome) {
+		retained += utils.ToCents(balance.Closing)
+	}
+	for _, balance := range book.balances(models.AccountTypeExpense) {
+		retained -= utils.ToCents(balance.Closing)
+	}
+	report.RetainedEarnings = utils.FromCents(retained)
+	report.TotalAssets = utils.FromCents(assets)
+	report.TotalLiabilities = utils.FromCents(liabilities)
+	report.TotalEquity = utils.FromCents(equity + retained)
+	report.Balanced = assets == liabilities+equity+retained
+	return report, nil
+}
+
+// IncomeStatement 利润表：期间内各收入、费用科目的发生额（Closing）和净利润
+func (s *BookService) IncomeStatement(userID uint, filter models.ReportFilter) (*models.IncomeStatement, error) {
+	ledgerID, dates, err := bookLedger(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	book, err := collectBook(userID, ledgerID, dates, false)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.IncomeStatement{
+		BaseCurrency: book.base,
+		StartDate:    filter.StartDate,
+		EndDate:      filter.EndDate,
+		Income:       book.balances(models.AccountTypeIncome),
+		Expenses:     book.balances(models.AccountTypeExpense),
+	}
+	var income, expense int64
+	for _, balance := range report.Income {
+		income += utils.ToCents(balance.Closing)
+	}
+	for _, balance := range report.Expenses {
+		expense += utils.ToCents(balance.Closing)
+	}
+	report.TotalIncome = utils.FromCents(income)
+	report.TotalExpense = utils.FromCents(expense)
+	report.NetIncome = utils.FromCents(income - expense)
+	return report, nil
+}
+
+// EntryPostings 返回账单换算出的分录（原币种），有拆分明细时按拆分行分别计入费用或收入科目
+func (s *BookService) EntryPostings(id, userID uint) ([]models.EntryPosting, error) {
+	var entry models.LedgerEntry
+	err := visibleEntries(config.DB, userID).Preload("Splits").Where("id = ?", id).First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	accounts, err := ledgerAccounts(config.DB, entry.LedgerID)
+	if err != nil {
+		return nil, err
+	}
+	mapper := newAccountMapper(accounts)
+
+	type part struct {
+		category string
+		amount   float64
+	}
+	parts := []part{{entry.Category, entry.Amount}}
+	if len(entry.Splits) > 0 {
+		parts = parts[:0]
+		for _, split := range entry.Splits {
+			parts = append(parts, part{split.Category, split.Amount})
+		}
+	}
+
+	// 借方在前、贷方在后，同一科目同一方向合并为一行
+	var debits, credits []bookAccount
+	cents := make(map[bookAccount]*[2]int64)
+	add := func(account bookAccount, side int, amount int64) {
+		if cents[account] == nil {
+			cents[account] = &[2]int64{}
+		}
+		if cents[account][side] == 0 {
+			if side == 0 {
+				debits = append(debits, account)
+			} else {
+				credits = append(credits, account)
+			}
+		}
+		cents[account][side] += amount
+	}
+	for _, p := range parts {
+		debit, credit := mapper.entryAccounts(entry.Type, p.category, entry.Account, entry.LoanID != nil)
+		amount := utils.ToCents(p.amount)
+		add(debit, 0, amount)
+		add(credit, 1, amount)
+	}
+
+	postings := make([]models.EntryPosting, 0, len(debits)+len(credits))
+	for side, list := range [][]bookAccount{debits, credits} {
+		for _, account := range list {
+			posting := models.EntryPosting{
+				AccountID: account.ID,
+				Code:      account.Code,
+				Name:      account.Name,
+				Type:      account.Type,
+				Currency:  entry.Currency,
+			}
+			if side == 0 {
+				posting.Debit = utils.FromCents(cents[account][0])
+			} else {
+				posting.Credit = utils.FromCents(cents[account][1])
+			}
+			postings = append(postings, posting)
+		}
+	}
+	return postings, nil
+}
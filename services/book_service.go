@@ -0,0 +1,377 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAccountNotFound 科目不存在或当前用户无权访问
+	ErrAccountNotFound = errors.New("科目不存在")
+	// ErrAccountInUse 科目已被凭证、对账或储蓄目标使用，不能删除
+	ErrAccountInUse = errors.New("科目已被凭证、对账或储蓄目标使用，不能删除")
+	// ErrJournalNotFound 凭证不存在或当前用户无权访问
+	ErrJournalNotFound = errors.New("凭证不存在")
+)
+
+// 默认科目的名称，账单换算为分录时使用
+const (
+	bookCashAccount       = models.AccountNameCash
+	bookReceivableAccount = models.AccountNameReceivable
+	bookPayableAccount    = models.AccountNamePayable
+)
+
+// 每张凭证最多的分录数
+const journalMaxPostings = 50
+
+var accountTypes = map[string]bool{
+	models.AccountTypeAsset:     true,
+	models.AccountTypeLiability: true,
+	models.AccountTypeEquity:    true,
+	models.AccountTypeIncome:    true,
+	models.AccountTypeExpense:   true,
+}
+
+type BookService struct{}
+
+// seedAccounts 为新建的账本创建默认科目，并发初始化时由唯一索引去重
+func seedAccounts(tx *gorm.DB, ledgerID uint) error {
+	accounts := models.DefaultAccounts(ledgerID)
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&accounts).Error
+}
+
+// ledgerAccounts 返回账本的科目表（按编码排序）
+// 默认科目在创建账本时生成（已有账本由启动迁移补齐），查询时不再写入
+func ledgerAccounts(db *gorm.DB, ledgerID uint) ([]models.Account, error) {
+	var accounts []models.Account
+	if err := db.Where("ledger_id = ?", ledgerID).Order("code").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ListAccounts 查询账本的科目表，ledgerID 为 0 时使用当前账本
+func (s *BookService) ListAccounts(userID, ledgerID uint) ([]models.Account, error) {
+	ledger, err := resolveLedger(ledgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return ledgerAccounts(config.DB, ledger.ID)
+}
+
+// CreateAccount 新增科目，account.LedgerID 为 0 时使用当前账本
+// 资产、负债科目的名称与账单的资金账户相同时，这些账单会记入该科目；收入、费用科目同理对应账单分类
+func (s *BookService) CreateAccount(userID uint, account *models.Account) error {
+	ledger, err := writableLedger(account.LedgerID, userID)
+	if err != nil {
+		return err
+	}
+	account.ID = 0
+	account.LedgerID = ledger.ID
+	if err := validateAccount(account); err != nil {
+		return err
+	}
+	return config.DB.Create(account).Error
+}
+
+// UpdateAccount 修改科目的编码、名称、类型和备注
+// 账单按名称记入科目，已有账单记入的科目不能改名或修改类型，否则这些账单会脱离该科目；
+// 已被凭证、对账或储蓄目标使用的科目也不能修改类型
+func (s *BookService) UpdateAccount(id, userID uint, input *models.Account) (*models.Account, error) {
+	account, err := writableAccount(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	renamed := strings.TrimSpace(input.Name) != account.Name
+	if renamed || input.Type != account.Type {
+		mapped, err := accountHasEntries(account)
+		if err != nil {
+			return nil, err
+		}
+		fields := utils.FieldErrors{}
+		if mapped && renamed {
+			fields["name"] = "已有账单记入该科目，不能修改名称"
+		}
+		if input.Type != account.Type {
+			used, err := accountUsed(account.ID)
+			if err != nil {
+				return nil, err
+			}
+			if used || mapped {
+				fields["type"] = "科目已被账单、凭证、对账或储蓄目标使用，不能修改类型"
+			}
+		}
+		if len(fields) > 0 {
+			return nil, fields
+		}
+	}
+
+	account.Code = input.Code
+	account.Name = input.Name
+	account.Type = input.Type
+	account.Remark = input.Remark
+	if err := validateAccount(account); err != nil {
+		return nil, err
+	}
+	err = config.DB.Model(account).Select("code", "name", "type", "remark").Updates(account).Error
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// DeleteAccount 删除未被凭证或对账使用的科目
+func (s *BookService) DeleteAccount(id, userID uint) error {
+	account, err := writableAccount(id, userID)
+	if err != nil {
+		return err
+	}
+	used, err := accountUsed(account.ID)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrAccountInUse
+	}
+	return config.DB.Delete(account).Error
+}
+
+// validateAccount 校验科目字段，并检查编码、名称在账本内不重复
+func validateAccount(account *models.Account) error {
+	account.Code = strings.TrimSpace(account.Code)
+	account.Name = strings.TrimSpace(account.Name)
+	account.Remark = strings.TrimSpace(account.Remark)
+
+	fields := utils.FieldErrors{}
+	if account.Code == "" || len(account.Code) > 20 {
+		fields["code"] = "编码不能为空且不超过 20 个字符"
+	}
+	if account.Name == "" || len([]rune(account.Name)) > 50 {
+		fields["name"] = "名称不能为空且不超过 50 个字符"
+	}
+	if !accountTypes[account.Type] {
+		fields["type"] = "只能是 asset、liability、equity、income 或 expense"
+	}
+	if len([]rune(account.Remark)) > 255 {
+		fields["remark"] = "备注不能超过 255 个字符"
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+
+	var existing []models.Account
+	if err := config.DB.Where("ledger_id = ? AND id <> ? AND (code = ? OR name = ?)",
+		account.LedgerID, account.ID, account.Code, account.Name).Find(&existing).Error; err != nil {
+		return err
+	}
+	for _, other := range existing {
+		if other.Code == account.Code {
+			fields["code"] = "编码已存在"
+		}
+		if other.Name == account.Name {
+			fields["name"] = "名称已存在"
+		}
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+// writableAccount 查找科目，要求当前用户至少是所属账本的编辑者，且账本未归档
+func writableAccount(id, userID uint) (*models.Account, error) {
+	var account models.Account
+	if err := config.DB.Where("id = ? AND ledger_id IN (?)", id, memberLedgerIDs(userID)).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	if _, err := writableLedger(account.LedgerID, userID); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// accountUsed 科目是否被凭证分录、对账记录或储蓄目标引用
+func accountUsed(accountID uint) (bool, error) {
+	for _, model := range []any{&models.Posting{}, &models.Reconciliation{}, &models.Goal{}} {
+		var count int64
+		if err := config.DB.Model(model).Where("account_id = ?", accountID).Count(&count).Error; err != nil || count > 0 {
+			return count > 0, err
+		}
+	}
+	return false, nil
+}
+
+// accountHasEntries 账本中是否有账单按名称记入该科目（含回收站中的账单），规则与 accountMapper 一致：
+// 资产、负债科目对应资金账户，应收、应付借款对应借贷产生的账单，收入、费用科目对应收支账单的分类或拆分行的分类
+func accountHasEntries(account *models.Account) (bool, error) {
+	entries := func() *gorm.DB {
+		return config.DB.Unscoped().Model(&models.LedgerEntry{}).Where("ledger_id = ?", account.LedgerID)
+	}
+	loanCategories := []string{loanCategoryBorrow, loanCategoryRepay}
+	var queries []*gorm.DB
+	switch account.Type {
+	case models.AccountTypeAsset, models.AccountTypeLiability:
+		queries = append(queries, whereEntryAccount(entries(), account.Name))
+		if account.Name == bookReceivableAccount && account.Type == models.AccountTypeAsset {
+			queries = append(queries, entries().Where("loan_id IS NOT NULL AND category NOT IN ?", loanCategories))
+		}
+		if account.Name == bookPayableAccount && account.Type == models.AccountTypeLiability {
+			queries = append(queries, entries().Where("loan_id IS NOT NULL AND category IN ?", loanCategories))
+		}
+	case models.AccountTypeIncome, models.AccountTypeExpense:
+		entryType := models.EntryTypeExpense
+		if account.Type == models.AccountTypeIncome {
+			entryType = models.EntryTypeIncome
+		}
+		queries = append(queries,
+			entries().Where("type = ? AND loan_id IS NULL AND category = ?", entryType, account.Name).
+				Where("NOT EXISTS (SELECT 1 FROM entry_splits WHERE entry_splits.entry_id = ledger_entries.id)"),
+			config.DB.Model(&models.EntrySplit{}).
+				Joins("JOIN ledger_entries ON ledger_entries.id = entry_splits.entry_id").
+				Where("ledger_entries.ledger_id = ? AND ledger_entries.type = ? AND ledger_entries.loan_id IS NULL AND entry_splits.category = ?",
+					account.LedgerID, entryType, account.Name),
+		)
+	}
+	for _, query := range queries {
+		var count int64
+		if err := query.Limit(1).Count(&count).Error; err != nil || count > 0 {
+			return count > 0, err
+		}
+	}
+	return false, nil
+}
+
+// CreateJournal 录入记账凭证，用于期初余额、转账、折旧等普通账单无法表达的业务
+// 至少两条分录，每条只能填借方或贷方，借贷合计必须相等；币种不填时使用录入人的本位币
+func (s *BookService) CreateJournal(userID uint, txn *models.JournalTransaction) error {
+	ledger, err := writableLedger(txn.LedgerID, userID)
+	if err != nil {
+		return err
+	}
+	accounts, err := ledgerAccounts(config.DB, ledger.ID)
+	if err != nil {
+		return err
+	}
+	known := make(map[uint]bool, len(accounts))
+	for _, account := range accounts {
+		known[account.ID] = true
+	}
+
+	fields := utils.FieldErrors{}
+	if txn.Currency, err = checkCurrency(txn.Currency); err != nil {
+		return err
+	}
+	txn.Memo = strings.TrimSpace(txn.Memo)
+	if len([]rune(txn.Memo)) > 255 {
+		fields["memo"] = "摘要不能超过 255 个字符"
+	}
+	if len(txn.Postings) < 2 || len(txn.Postings) > journalMaxPostings {
+		fields["postings"] = fmt.Sprintf("分录数量必须在 2 到 %d 之间", journalMaxPostings)
+	}
+	var debit, credit int64
+	for i := range txn.Postings {
+		posting := &txn.Postings[i]
+		key := fmt.Sprintf("postings[%d]", i)
+		d, c := utils.ToCents(posting.Debit), utils.ToCents(posting.Credit)
+		switch {
+		case !known[posting.AccountID]:
+			fields[key] = "科目不存在"
+		case d < 0 || c < 0 || (d > 0) == (c > 0):
+			fields[key] = "借方、贷方金额只能填一个且必须大于 0"
+		case len([]rune(posting.Memo)) > 255:
+			fields[key] = "摘要不能超过 255 个字符"
+		}
+		posting.ID = 0
+		posting.Debit, posting.Credit = utils.FromCents(d), utils.FromCents(c)
+		debit += d
+		credit += c
+	}
+	if len(fields) == 0 && debit != credit {
+		fields["postings"] = fmt.Sprintf("借方合计 %.2f 不等于贷方合计 %.2f", utils.FromCents(debit), utils.FromCents(credit))
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+
+	if txn.Currency == "" {
+		if txn.Currency, err = baseCurrency(config.DB, userID); err != nil {
+			return err
+		}
+	}
+	txn.ID = 0
+	txn.LedgerID = ledger.ID
+	txn.UserID = userID
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkPeriodOpen(tx, ledger.ID, txn.Date); err != nil {
+			return err
+		}
+		return tx.Create(txn).Error
+	})
+}
+
+// ListJournal 分页查询账本中手工录入的凭证，按日期倒序
+func (s *BookService) ListJournal(userID uint, filter models.ReportFilter, page, pageSize int) ([]models.JournalTransaction, int64, error) {
+	ledger, err := resolveLedger(filter.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, 0, err
+	}
+	dates, err := parseReportDates(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := config.DB.Model(&models.JournalTransaction{}).Where("ledger_id = ?", ledger.ID)
+	if dates.from != "" {
+		query = query.Where("date >= ?", dates.from)
+	}
+	if dates.before != "" {
+		query = query.Where("date < ?", dates.before)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var transactions []models.JournalTransaction
+	err = query.Preload("Postings").
+		Order("date DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// DeleteJournal 删除手工录入的凭证及其分录
+func (s *BookService) DeleteJournal(id, userID uint) error {
+	var txn models.JournalTransaction
+	if err := config.DB.Where("id = ? AND ledger_id IN (?)", id, memberLedgerIDs(userID)).First(&txn).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrJournalNotFound
+		}
+		return err
+	}
+	if _, err := writableLedger(txn.LedgerID, userID); err != nil {
+		return err
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkPeriodOpen(tx, txn.LedgerID, txn.Date); err != nil {
+			return err
+		}
+		if err := tx.Where("transaction_id = ?", txn.ID).Delete(&models.Posting{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&txn).Error
+	})
+}
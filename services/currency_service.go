@@ -0,0 +1,270 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrExchangeRateNotFound 汇率记录不存在或无权访问
+	ErrExchangeRateNotFound = errors.New("汇率记录不存在或无权访问")
+	// ErrRateMissing 换算时找不到可用的汇率，具体币种和日期会附加在错误信息中
+	ErrRateMissing = errors.New("缺少汇率")
+)
+
+// 汇率导入文件最多显示的错误行数，避免响应过大
+const rateImportMaxErrors = 20
+
+type CurrencyService struct{}
+
+// SetBaseCurrency 修改本位币，已有账单的原始币种和金额不变，报表按新本位币换算
+func (s *CurrencyService) SetBaseCurrency(userID uint, code string) (string, error) {
+	currency, ok := utils.NormalizeCurrency(code)
+	if !ok {
+		return "", utils.FieldErrors{"currency": "币种必须是三位字母代码，如 CNY、USD"}
+	}
+	err := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("base_currency", currency).Error
+	return currency, err
+}
+
+// SaveRate 新增或覆盖某一天的汇率
+func (s *CurrencyService) SaveRate(userID uint, rate *models.ExchangeRate) error {
+	if fields := validateRate(rate); len(fields) > 0 {
+		return fields
+	}
+	rate.UserID = userID
+	return upsertRates(config.DB, []models.ExchangeRate{*rate})
+}
+
+// ListRates 查询汇率，按日期倒序
+func (s *CurrencyService) ListRates(userID uint, filter models.ExchangeRateFilter) ([]models.ExchangeRate, error) {
+	fields := utils.FieldErrors{}
+	parseDateParam(filter.StartDate, "start_date", fields)
+	parseDateParam(filter.EndDate, "end_date", fields)
+	if len(fields) > 0 {
+		return nil, fields
+	}
+
+	query := config.DB.Where("user_id = ?", userID)
+	if filter.FromCurrency != "" {
+		query = query.Where("from_currency = ?", strings.ToUpper(filter.FromCurrency))
+	}
+	if filter.ToCurrency != "" {
+		query = query.Where("to_currency = ?", strings.ToUpper(filter.ToCurrency))
+	}
+	if filter.StartDate != "" {
+		query = query.Where("date >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query = query.Where("date <= ?", filter.EndDate)
+	}
+
+	var rates []models.ExchangeRate
+	err := query.Order("date desc, from_currency, to_currency").Find(&rates).Error
+	return rates, err
+}
+
+// DeleteRate 删除一条汇率
+func (s *CurrencyService) DeleteRate(id, userID uint) error {
+	result := config.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.ExchangeRate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrExchangeRateNotFound
+	}
+	return nil
+}
+
+// ImportRates 从 CSV 导入汇率，每行格式为 date,from_currency,to_currency,rate
+// 第一行可以是表头；任意一行有误则整体不导入，返回出错的行号
+func (s *CurrencyService) ImportRates(userID uint, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rates []models.ExchangeRate
+	fields := utils.FieldErrors{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, utils.FieldErrors{"file": "CSV 格式错误: " + err.Error()}
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		if len(fields) >= rateImportMaxErrors {
+			continue
+		}
+
+		key := fmt.Sprintf("line_%d", line)
+		if len(record) != 4 {
+			fields[key] = "每行必须是 date,from_currency,to_currency,rate 四列"
+			continue
+		}
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(record[0]))
+		if err != nil {
+			fields[key] = "日期格式必须为 YYYY-MM-DD"
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[3]), 64)
+		if err != nil {
+			fields[key] = "汇率必须是数字"
+			continue
+		}
+		rate := models.ExchangeRate{
+			UserID:       userID,
+			FromCurrency: record[1],
+			ToCurrency:   record[2],
+			Date:         date,
+			Rate:         value,
+		}
+		if errs := validateRate(&rate); len(errs) > 0 {
+			fields[key] = errs.Error()
+			continue
+		}
+		rates = append(rates, rate)
+	}
+	if len(fields) > 0 {
+		return 0, fields
+	}
+	if len(rates) == 0 {
+		return 0, utils.FieldErrors{"file": "文件中没有汇率数据"}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return upsertRates(tx, rates)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// validateRate 校验并规范化汇率的币种代码
+func validateRate(rate *models.ExchangeRate) utils.FieldErrors {
+	fields := utils.FieldErrors{}
+	from, okFrom := utils.NormalizeCurrency(rate.FromCurrency)
+	to, okTo := utils.NormalizeCurrency(rate.ToCurrency)
+	if !okFrom {
+		fields["from_currency"] = "币种必须是三位字母代码"
+	}
+	if !okTo {
+		fields["to_currency"] = "币种必须是三位字母代码"
+	}
+	if okFrom && okTo && from == to {
+		fields["to_currency"] = "两种币种不能相同"
+	}
+	if rate.Rate <= 0 {
+		fields["rate"] = "汇率必须大于 0"
+	}
+	if rate.Date.IsZero() {
+		fields["date"] = "日期不能为空"
+	}
+	rate.FromCurrency, rate.ToCurrency = from, to
+	return fields
+}
+
+// upsertRates 按 (用户, 币种对, 日期) 写入汇率，已存在则覆盖
+func upsertRates(tx *gorm.DB, rates []models.ExchangeRate) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "from_currency"}, {Name: "to_currency"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).CreateInBatches(rates, 500).Error
+}
+
+// checkCurrency 规范化账单等业务中的币种字段，空字符串表示使用创建人的本位币
+func checkCurrency(code string) (string, error) {
+	if strings.TrimSpace(code) == "" {
+		return "", nil
+	}
+	currency, ok := utils.NormalizeCurrency(code)
+	if !ok {
+		return "", utils.FieldErrors{"currency": "币种必须是三位字母代码，如 CNY、USD"}
+	}
+	return currency, nil
+}
+
+// baseCurrency 查询用户的本位币
+func baseCurrency(tx *gorm.DB, userID uint) (string, error) {
+	var user models.User
+	if err := tx.Select("id, base_currency").First(&user, userID).Error; err != nil {
+		return "", err
+	}
+	if user.BaseCurrency == "" {
+		return models.DefaultCurrency, nil
+	}
+	return user.BaseCurrency, nil
+}
+
+// rateConverter 把金额换算为用户的本位币，同一币种同一天的汇率只查询一次
+type rateConverter struct {
+	userID uint
+	base   string
+	cache  map[string]float64
+}
+
+func newRateConverter(userID uint) (*rateConverter, error) {
+	base, err := baseCurrency(config.DB, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &rateConverter{userID: userID, base: base, cache: make(map[string]float64)}, nil
+}
+
+// convert 使用 date 当天或之前最近的汇率换算金额，结果按分四舍五入
+func (c *rateConverter) convert(amount float64, currency string, date time.Time) (float64, error) {
+	if currency == "" || currency == c.base {
+		return amount, nil
+	}
+	day := date.Format("2006-01-02")
+	key := currency + "@" + day
+	rate, ok := c.cache[key]
+	if !ok {
+		var err error
+		if rate, err = c.lookup(currency, date); err != nil {
+			return 0, err
+		}
+		c.cache[key] = rate
+	}
+	return utils.FromCents(utils.ToCents(amount * rate)), nil
+}
+
+// lookup 先找 currency→本位币 的汇率，找不到再用 本位币→currency 的汇率取倒数
+func (c *rateConverter) lookup(currency string, date time.Time) (float64, error) {
+	// 用 "早于次日" 代替 "不晚于当天"，不受日期列存储精度影响
+	next := date.AddDate(0, 0, 1).Format("2006-01-02")
+	var rate models.ExchangeRate
+	err := config.DB.Where("user_id = ? AND from_currency = ? AND to_currency = ? AND date < ?", c.userID, currency, c.base, next).
+		Order("date desc").First(&rate).Error
+	if err == nil {
+		return rate.Rate, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	err = config.DB.Where("user_id = ? AND from_currency = ? AND to_currency = ? AND date < ?", c.userID, c.base, currency, next).
+		Order("date desc").First(&rate).Error
+	if err == nil {
+		return 1 / rate.Rate, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%w：%s 兑 %s 在 %s 及之前没有汇率", ErrRateMissing, currency, c.base, date.Format("2006-01-02"))
+}
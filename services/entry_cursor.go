@@ -0,0 +1,78 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// entryCursor 游标分页的位置：上一页最后一条账单的排序值和 ID
+// 编码为 base64 JSON 交给客户端，客户端只需原样传回，不应解析其内容
+// 游标中记录了排序方式，换了排序参数的游标视为无效，防止跳页或重复
+type entryCursor struct {
+	Sort   string     `json:"s"`
+	Order  string     `json:"o"`
+	Time   *time.Time `json:"t,omitempty"` // date、created_at、updated_at 排序时的值
+	Amount *float64   `json:"a,omitempty"` // amount 排序时的值
+	ID     uint       `json:"id"`
+}
+
+// newEntryCursor 根据一页中的最后一条账单生成下一页的游标
+func newEntryCursor(sort entrySort, entry models.LedgerEntry) string {
+	cursor := entryCursor{Sort: sort.Field, Order: sort.Order, ID: entry.ID}
+	switch sort.Field {
+	case "amount":
+		cursor.Amount = &entry.Amount
+	case "created_at":
+		cursor.Time = &entry.CreatedAt
+	case "updated_at":
+		cursor.Time = &entry.UpdatedAt
+	default:
+		cursor.Time = &entry.Date
+	}
+	// 结构体只含基本类型，序列化不会失败
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseEntryCursor 解析游标并校验与当前排序一致
+func parseEntryCursor(value string, sort entrySort) (*entryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, utils.FieldErrors{"cursor": "无效的游标"}
+	}
+	var cursor entryCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, utils.FieldErrors{"cursor": "无效的游标"}
+	}
+	if cursor.Sort != sort.Field || cursor.Order != sort.Order {
+		return nil, utils.FieldErrors{"cursor": "游标与当前的排序参数不一致"}
+	}
+	if (sort.Field == "amount" && cursor.Amount == nil) || (sort.Field != "amount" && cursor.Time == nil) {
+		return nil, utils.FieldErrors{"cursor": "无效的游标"}
+	}
+	return &cursor, nil
+}
+
+// seek 只查询排在游标之后的账单，与 entrySort.orderBy 的顺序一致
+func (c *entryCursor) seek(query *gorm.DB) *gorm.DB {
+	column := entrySortColumns[c.Sort]
+	op := "<"
+	if c.Order == "asc" {
+		op = ">"
+	}
+	var value interface{}
+	if c.Amount != nil {
+		value = *c.Amount
+	} else {
+		value = *c.Time
+	}
+	return query.Where("("+column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?))", value, value, c.ID)
+}
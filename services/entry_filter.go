@@ -0,0 +1,205 @@
+package services
+
+import (
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// entrySortColumns 允许排序的字段及对应的列
+var entrySortColumns = map[string]string{
+	"date":       "date",
+	"amount":     "amount",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// 筛选参数中逗号分隔的值最多个数
+const filterMaxValues = 20
+
+// entrySort 账单列表的排序方式
+type entrySort struct {
+	Field string // 排序参数，即 entrySortColumns 的 key
+	Order string // asc 或 desc
+}
+
+// orderBy 返回 ORDER BY 子句；同值时按 id 排序保证翻页稳定
+func (s entrySort) orderBy() string {
+	column := entrySortColumns[s.Field]
+	return column + " " + s.Order + ", id " + s.Order
+}
+
+// applyEntryFilter 校验筛选和排序参数，把筛选条件追加到查询上，并返回排序方式
+// 参数不合法时返回 utils.FieldErrors，key 为出错的查询参数名
+func applyEntryFilter(query *gorm.DB, ledgerID uint, filter models.EntryFilter) (*gorm.DB, entrySort, error) {
+	fields := utils.FieldErrors{}
+	sort := entryOrder(filter, fields)
+
+	switch filter.Type {
+	case "", "0":
+	case strconv.Itoa(models.EntryTypeIncome), strconv.Itoa(models.EntryTypeExpense):
+		query = query.Where("type = ?", filter.Type)
+	default:
+		fields["type"] = "只能是 1（收入）或 2（支出）"
+	}
+
+	if categories := splitListParam(filter.Category); len(categories) > filterMaxValues {
+		fields["category"] = fmt.Sprintf("最多 %d 个", filterMaxValues)
+	} else if len(categories) > 0 {
+		query = query.Where("category IN ? OR id IN (?)", categories,
+			config.DB.Model(&models.EntrySplit{}).Select("entry_id").Where("category IN ?", categories))
+	}
+	if accounts := splitListParam(filter.Account); len(accounts) > filterMaxValues {
+		fields["account"] = fmt.Sprintf("最多 %d 个", filterMaxValues)
+	} else if len(accounts) > 0 {
+		query = query.Where("account IN ?", accounts)
+	}
+
+	minAmount, okMin := parseAmountParam(filter.MinAmount, "min_amount", fields)
+	maxAmount, okMax := parseAmountParam(filter.MaxAmount, "max_amount", fields)
+	if okMin && okMax && minAmount > maxAmount {
+		fields["max_amount"] = "不能小于 min_amount"
+	}
+	if okMin {
+		query = query.Where("amount >= ?", minAmount)
+	}
+	if okMax {
+		query = query.Where("amount <= ?", maxAmount)
+	}
+
+	if keyword := strings.TrimSpace(filter.Keyword); keyword != "" {
+		if len([]rune(keyword)) > 50 {
+			fields["keyword"] = "不能超过 50 个字符"
+		} else {
+			query = query.Where("remark LIKE ? ESCAPE '!'", "%"+escapeLike(keyword)+"%")
+		}
+	}
+
+	startDate, okStart := parseDateParam(filter.StartDate, "start_date", fields)
+	endDate, okEnd := parseDateParam(filter.EndDate, "end_date", fields)
+	if okStart && okEnd && startDate.After(endDate) {
+		fields["end_date"] = "不能早于 start_date"
+	}
+	if okStart {
+		query = query.Where("date >= ?", startDate.Format(utils.DateLayout))
+	}
+	if okEnd {
+		// 用 "早于次日" 代替 "不晚于当天"，不受日期列存储精度影响
+		query = query.Where("date < ?", endDate.AddDate(0, 0, 1).Format(utils.DateLayout))
+	}
+
+	query = applyTimeRange(query, "created_at", filter.CreatedFrom, filter.CreatedTo, "created_from", "created_to", fields)
+	query = applyTimeRange(query, "updated_at", filter.UpdatedFrom, filter.UpdatedTo, "updated_from", "updated_to", fields)
+
+	query = filterByTags(query, ledgerID, splitTagParam(filter.TagsAny), splitTagParam(filter.TagsAll))
+
+	if len(fields) > 0 {
+		return nil, sort, fields
+	}
+	return query, sort, nil
+}
+
+// entryOrder 校验排序参数
+func entryOrder(filter models.EntryFilter, fields utils.FieldErrors) entrySort {
+	sort := filter.Sort
+	if sort == "" {
+		sort = "date"
+	}
+	if _, ok := entrySortColumns[sort]; !ok {
+		fields["sort"] = "只能是 date、amount、created_at 或 updated_at"
+	}
+	order := strings.ToLower(filter.Order)
+	if order == "" {
+		order = "desc"
+	}
+	if order != "asc" && order != "desc" {
+		fields["order"] = "只能是 asc 或 desc"
+	}
+	return entrySort{Field: sort, Order: order}
+}
+
+// splitListParam 解析逗号分隔的参数，忽略空项
+func splitListParam(param string) []string {
+	var values []string
+	for _, v := range strings.Split(param, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// parseAmountParam 解析金额参数，必须是非负数
+func parseAmountParam(value, name string, fields utils.FieldErrors) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		fields[name] = "必须是非负数"
+		return 0, false
+	}
+	return amount, true
+}
+
+// parseDateParam 解析 YYYY-MM-DD 日期参数
+func parseDateParam(value, name string, fields utils.FieldErrors) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, ok := utils.ParseDate(value)
+	if !ok {
+		fields[name] = "日期格式必须为 YYYY-MM-DD"
+	}
+	return t, ok
+}
+
+// applyTimeRange 按时间区间筛选，上限只给日期时包含当天
+func applyTimeRange(query *gorm.DB, column, from, to, fromName, toName string, fields utils.FieldErrors) *gorm.DB {
+	var start, end time.Time
+	var hasStart, hasEnd bool
+	if from != "" {
+		t, _, ok := utils.ParseDateTime(from)
+		if !ok {
+			fields[fromName] = "格式必须为 RFC3339 时间或 YYYY-MM-DD"
+		} else {
+			start, hasStart = t, true
+		}
+	}
+	if to != "" {
+		t, dateOnly, ok := utils.ParseDateTime(to)
+		if !ok {
+			fields[toName] = "格式必须为 RFC3339 时间或 YYYY-MM-DD"
+		} else {
+			if dateOnly {
+				t = t.AddDate(0, 0, 1)
+			} else {
+				t = t.Add(time.Nanosecond)
+			}
+			end, hasEnd = t, true
+		}
+	}
+	if hasStart && hasEnd && !start.Before(end) {
+		fields[toName] = "不能早于 " + fromName
+		return query
+	}
+	if hasStart {
+		query = query.Where(column+" >= ?", start)
+	}
+	if hasEnd {
+		query = query.Where(column+" < ?", end)
+	}
+	return query
+}
+
+// escapeLike 转义 LIKE 中的通配符，配合 ESCAPE '!' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
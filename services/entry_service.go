@@ -1,48 +1,374 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"go-ledger/config"
 	"go-ledger/models"
+	"go-ledger/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrEntryNotFound 账单不存在或当前用户无权访问
+	ErrEntryNotFound = errors.New("账单不存在或无权删除")
+	// ErrEntryLinked 账单由借贷、分摊等业务自动生成，需要通过对应的业务接口修改
+	ErrEntryLinked = errors.New("该账单由借贷或分摊记录生成，请通过借贷或分摊接口修改")
+	// ErrEntryModified 账单在客户端获取之后已被修改（If-Match 与当前版本不一致）
+	ErrEntryModified = errors.New("账单已被其他人修改，请刷新后重试")
+	// ErrEntryLocked 账单已完成对账并锁定，需要先解锁才能修改或删除
+	ErrEntryLocked = errors.New("账单已对账锁定，请先解锁")
 )
 
+// 每条账单最多的拆分行数
+const entryMaxSplits = 50
+
 type EntryService struct{}
 
 // CreateEntry 创建账单
-func (s *EntryService) CreateEntry(entry *models.LedgerEntry) error {
-	return config.DB.Create(entry).Error
+// entry.UserID 为创建人；entry.LedgerID 为空时记入创建人的当前账本，要求创建人至少是该账本的编辑者
+// entry.Tags 只需填写名称，账本中不存在的标签会自动创建；entry.Splits 不为空时各行金额之和必须等于 Amount
+func (s *EntryService) CreateEntry(ctx context.Context, entry *models.LedgerEntry) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return addEntry(tx, entry)
+	})
 }
 
-// FindEntries 查询账单列表
-func (s *EntryService) FindEntries(userID uint, filter models.EntryFilter, page, pageSize int) ([]models.LedgerEntry, int64, error) {
-	var entries []models.LedgerEntry
-	var total int64
+// GetEntry 查询单条账单（含标签和拆分明细），要求当前用户是账单所属账本的成员
+func (s *EntryService) GetEntry(id, userID uint) (*models.LedgerEntry, error) {
+	var entry models.LedgerEntry
+	err := visibleEntries(config.DB, userID).Preload("Tags").Preload("Splits").Where("id = ?", id).First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
 
-	// 1. 初始化查询
-	query := config.DB.Model(&models.LedgerEntry{}).Where("user_id = ?", userID)
+// UpdateEntry 修改账单的类型、金额、币种、分类、日期、备注、标签和拆分明细（标签、拆分整体替换）
+// 所属账本和创建人不变；借贷生成的账单需要通过借贷接口修改
+// version 为客户端看到的账单版本（sync_seq），与当前版本不一致时返回 ErrEntryModified；为 nil 时不校验
+func (s *EntryService) UpdateEntry(ctx context.Context, id, userID uint, version *uint64, input *models.LedgerEntry) (*models.LedgerEntry, error) {
+	var entry *models.LedgerEntry
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkEntryVersion(tx, id, userID, version); err != nil {
+			return err
+		}
+		var err error
+		entry, err = editEntry(tx, id, userID, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
 
-	// 2. 动态添加筛选条件
-	if filter.Type > 0 {
-		query = query.Where("type = ?", filter.Type)
+// checkEntryVersion 锁住账单行并比较版本，保证比较和写入之间不会插入其他修改
+func checkEntryVersion(tx *gorm.DB, id, userID uint, version *uint64) error {
+	if version == nil {
+		return nil
+	}
+	var entry models.LedgerEntry
+	err := visibleEntries(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID).Where("id = ?", id).First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEntryNotFound
+		}
+		return err
+	}
+	if entry.SyncSeq != *version {
+		return ErrEntryModified
 	}
-	if filter.Category != "" {
-		query = query.Where("category = ?", filter.Category)
+	return nil
+}
+
+// addEntry 在事务中校验权限和参数后创建账单，供单条和批量新增共用
+func addEntry(tx *gorm.DB, entry *models.LedgerEntry) error {
+	ledger, err := writableLedger(entry.LedgerID, entry.UserID)
+	if err != nil {
+		return err
+	}
+	if entry.Currency, err = checkCurrency(entry.Currency); err != nil {
+		return err
+	}
+	if err := validateSplits(entry.Amount, entry.Splits); err != nil {
+		return err
+	}
+	entry.Account = strings.TrimSpace(entry.Account)
+	entry.LedgerID = ledger.ID
+	if entry.Tags, err = resolveTags(tx, ledger.ID, entry.Tags); err != nil {
+		return err
+	}
+	return createEntry(tx, entry)
+}
+
+// editEntry 在事务中校验权限和参数后修改账单，供单条和批量修改共用
+func editEntry(tx *gorm.DB, id, userID uint, input *models.LedgerEntry) (*models.LedgerEntry, error) {
+	var entry models.LedgerEntry
+	if err := visibleEntries(tx, userID).Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	if _, err := writableLedger(entry.LedgerID, userID); err != nil {
+		return nil, err
+	}
+	if err := checkEntryUnlinked(tx, &entry); err != nil {
+		return nil, err
+	}
+	currency, err := checkCurrency(input.Currency)
+	if err != nil {
+		return nil, err
 	}
-	if filter.StartDate != "" {
-		query = query.Where("date >= ?", filter.StartDate)
+	if currency == "" {
+		currency = entry.Currency
 	}
-	if filter.EndDate != "" {
-		query = query.Where("date <= ?", filter.EndDate)
+	if err := validateSplits(input.Amount, input.Splits); err != nil {
+		return nil, err
 	}
 
-	// 3. 统计总数
-	if err := query.Count(&total).Error; err != nil {
+	entry.Type = input.Type
+	entry.Amount = input.Amount
+	entry.Currency = currency
+	entry.Category = input.Category
+	entry.Account = strings.TrimSpace(input.Account)
+	entry.Date = input.Date
+	entry.Remark = input.Remark
+	entry.Splits = input.Splits
+	if entry.Tags, err = resolveTags(tx, entry.LedgerID, input.Tags); err != nil {
+		return nil, err
+	}
+	if err := updateEntry(tx, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// checkEntryUnlinked 借贷生成的账单和未删除的分摊对应的账单不能直接修改、删除，返回 ErrEntryLinked
+func checkEntryUnlinked(tx *gorm.DB, entry *models.LedgerEntry) error {
+	if entry.LoanID != nil {
+		return ErrEntryLinked
+	}
+	var shared int64
+	err := tx.Model(&models.SharedExpenseShare{}).
+		Joins("JOIN shared_expenses ON shared_expenses.id = shared_expense_shares.shared_expense_id").
+		Where("shared_expense_shares.entry_id = ? AND shared_expenses.deleted_at IS NULL", entry.ID).
+		Count(&shared).Error
+	if err != nil {
+		return err
+	}
+	if shared > 0 {
+		return ErrEntryLinked
+	}
+	return nil
+}
+
+// removeEntry 在事务中校验权限后把账单移入回收站，供单条和批量删除共用
+func removeEntry(tx *gorm.DB, id, userID uint) error {
+	var entry models.LedgerEntry
+	if err := visibleEntries(tx, userID).Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEntryNotFound
+		}
+		return err
+	}
+	if _, err := writableLedger(entry.LedgerID, userID); err != nil {
+		return err
+	}
+	if err := checkEntryUnlinked(tx, &entry); err != nil {
+		return err
+	}
+
+	deleted, err := deleteEntries(tx, []uint{entry.ID})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrEntryNotFound
+	}
+	return nil
+}
+
+// deleteEntries 把账单移入回收站（软删除），分配新的变更序号并写入审计日志，返回删除的数量
+// 所有删除账单的路径都应经过这里；包含已对账锁定或已结账期间内的账单时整体拒绝
+func deleteEntries(tx *gorm.DB, ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	snapshots, err := entrySnapshots(tx, ids)
+	if err != nil {
+		return 0, err
+	}
+	before := make([]models.LedgerEntry, 0, len(snapshots))
+	dates := make(map[uint][]time.Time)
+	for _, entry := range snapshots {
+		if entry.Locked {
+			return 0, ErrEntryLocked
+		}
+		if !entry.DeletedAt.Valid {
+			before = append(before, entry)
+			dates[entry.LedgerID] = append(dates[entry.LedgerID], entry.Date)
+		}
+	}
+	for ledgerID, ledgerDates := range dates {
+		if err := checkPeriodOpen(tx, ledgerID, ledgerDates...); err != nil {
+			return 0, err
+		}
+	}
+	// 在进行中的对账里已勾选的账单删除时取消勾选，恢复后需要重新核对
+	if err := unclearOpenReconciliations(tx, before); err != nil {
+		return 0, err
+	}
+	if err := touchEntries(tx, ids); err != nil {
+		return 0, err
+	}
+	result := tx.Where("id IN ?", ids).Delete(&models.LedgerEntry{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if err := auditRemovedEntries(tx, models.AuditActionEntryDelete, before); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected, nil
+}
+
+// createEntry 在指定连接（可以是事务）中写入账单，调用方负责权限校验
+// 所有新增账单的路径都应经过这里，便于统一处理写入前后的逻辑
+// 未指定币种时使用创建人的本位币；日期早于账本结账日期时拒绝写入
+func createEntry(tx *gorm.DB, entry *models.LedgerEntry) error {
+	if entry.Currency == "" {
+		currency, err := baseCurrency(tx, entry.UserID)
+		if err != nil {
+			return err
+		}
+		entry.Currency = currency
+	}
+	if err := checkPeriodOpen(tx, entry.LedgerID, entry.Date); err != nil {
+		return err
+	}
+	seq, err := nextSyncSeq(tx, entry.LedgerID)
+	if err != nil {
+		return err
+	}
+	entry.SyncSeq = seq
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+	return auditEntry(tx, models.AuditActionEntryCreate, nil, entry)
+}
+
+// updateEntry 在指定连接中保存账单的可修改字段并替换标签和拆分明细，调用方负责权限校验
+// 与 createEntry 一样，所有修改账单的路径都应经过这里；已对账锁定、已结账期间内的账单拒绝修改
+// 在进行中的对账里已勾选的账单，修改了影响对账的字段（类型、金额、币种、资金账户、日期）时取消勾选，需要重新核对
+func updateEntry(tx *gorm.DB, entry *models.LedgerEntry) error {
+	before, err := entrySnapshot(tx, entry.ID)
+	if err != nil {
+		return err
+	}
+	if before.Locked {
+		return ErrEntryLocked
+	}
+	// 原日期和新日期都不能落在已结账的期间内
+	if err := checkPeriodOpen(tx, entry.LedgerID, before.Date, entry.Date); err != nil {
+		return err
+	}
+	columns := []string{"type", "amount", "currency", "category", "account", "date", "remark", "sync_seq"}
+	if before.ReconciliationID != nil && reconciledFieldsChanged(before, entry) {
+		open, err := openReconciliations(tx, []uint{*before.ReconciliationID})
+		if err != nil {
+			return err
+		}
+		if open[*before.ReconciliationID] {
+			entry.ReconciliationID = nil
+			columns = append(columns, "reconciliation_id")
+		}
+	}
+	seq, err := nextSyncSeq(tx, entry.LedgerID)
+	if err != nil {
+		return err
+	}
+	entry.SyncSeq = seq
+	err = tx.Model(entry).Select(columns).Updates(entry).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(entry).Association("Tags").Replace(entry.Tags); err != nil {
+		return err
+	}
+	if err := tx.Where("entry_id = ?", entry.ID).Delete(&models.EntrySplit{}).Error; err != nil {
+		return err
+	}
+	for i := range entry.Splits {
+		entry.Splits[i].ID = 0
+		entry.Splits[i].EntryID = entry.ID
+	}
+	if len(entry.Splits) > 0 {
+		if err := tx.Create(&entry.Splits).Error; err != nil {
+			return err
+		}
+	}
+	return auditEntry(tx, models.AuditActionEntryUpdate, before, entry)
+}
+
+// validateSplits 校验拆分明细：至少两行，每行分类不能为空、金额大于 0，合计等于账单金额
+func validateSplits(amount float64, splits []models.EntrySplit) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	if len(splits) < 2 || len(splits) > entryMaxSplits {
+		return utils.FieldErrors{"splits": fmt.Sprintf("拆分明细需要 2 到 %d 行", entryMaxSplits)}
+	}
+	var total int64
+	for i := range splits {
+		splits[i].Category = strings.TrimSpace(splits[i].Category)
+		if splits[i].Category == "" || len([]rune(splits[i].Category)) > 50 {
+			return utils.FieldErrors{"splits": fmt.Sprintf("第 %d 行分类不能为空且不超过 50 个字符", i+1)}
+		}
+		cents := utils.ToCents(splits[i].Amount)
+		if cents <= 0 {
+			return utils.FieldErrors{"splits": fmt.Sprintf("第 %d 行金额必须大于 0", i+1)}
+		}
+		total += cents
+	}
+	if total != utils.ToCents(amount) {
+		return utils.FieldErrors{"splits": fmt.Sprintf("拆分金额之和 %.2f 不等于账单金额 %.2f", utils.FromCents(total), amount)}
+	}
+	return nil
+}
+
+// visibleEntries 限定为用户已加入账本中的账单
+func visibleEntries(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Where("ledger_id IN (?)", memberLedgerIDs(userID))
+}
+
+// FindEntries 按页码分页查询账单，withTotal 为 false 时不统计总数（返回 -1），省去一次 COUNT 查询
+func (s *EntryService) FindEntries(userID uint, filter models.EntryFilter, page, pageSize int, withTotal bool) ([]models.LedgerEntry, int64, error) {
+	var entries []models.LedgerEntry
+	total := int64(-1)
+
+	query, sort, err := entryListQuery(userID, filter)
+	if err != nil {
 		return nil, 0, err
 	}
+	if withTotal {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, 0, err
+		}
+	}
 
-	// 4. 分页查询
 	offset := (page - 1) * pageSize
-	err := query.Order("date desc").
+	err = query.Preload("Tags").
+		Preload("Splits").
+		Order(sort.orderBy()).
 		Offset(offset).
 		Limit(pageSize).
 		Find(&entries).Error
@@ -54,21 +380,66 @@ func (s *EntryService) FindEntries(userID uint, filter models.EntryFilter, page,
 	return entries, total, nil
 }
 
-// DeleteEntry 删除账单
-func (s *EntryService) DeleteEntry(id string, userID uint) error {
-	// 增加 userID 校验，确保只能删除自己的账单
-	// 使用 Where("id = ? AND user_id = ?", id, userID) 来限制删除范围
-	// 如果记录不存在或不属于该用户，RowsAffected 将为 0
+// FindEntriesByCursor 按游标（keyset）分页查询账单
+// cursor 为空表示第一页；返回的 nextCursor 为空表示没有更多数据
+// 翻页期间新增或删除账单不会导致重复或遗漏，且不随页数增加变慢
+func (s *EntryService) FindEntriesByCursor(userID uint, filter models.EntryFilter, cursor string, limit int, withTotal bool) ([]models.LedgerEntry, string, int64, error) {
+	var entries []models.LedgerEntry
+	total := int64(-1)
+
+	query, sort, err := entryListQuery(userID, filter)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if withTotal {
+		if err := query.Count(&total).Error; err != nil {
+			return nil, "", 0, err
+		}
+	}
 
-	result := config.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.LedgerEntry{})
+	if cursor != "" {
+		position, err := parseEntryCursor(cursor, sort)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		query = position.seek(query)
+	}
 
-	if result.Error != nil {
-		return result.Error
+	// 多查一条判断是否还有下一页
+	err = query.Preload("Tags").
+		Preload("Splits").
+		Order(sort.orderBy()).
+		Limit(limit + 1).
+		Find(&entries).Error
+	if err != nil {
+		return nil, "", 0, err
 	}
 
-	if result.RowsAffected == 0 {
-		return errors.New("账单不存在或无权删除")
+	nextCursor := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		nextCursor = newEntryCursor(sort, entries[limit-1])
 	}
+	return entries, nextCursor, total, nil
+}
 
-	return nil
+// entryListQuery 确定账本（未指定时使用当前账本），校验筛选参数并返回查询和排序方式
+func entryListQuery(userID uint, filter models.EntryFilter) (*gorm.DB, entrySort, error) {
+	ledger, err := resolveLedger(filter.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, entrySort{}, err
+	}
+	query := config.DB.Model(&models.LedgerEntry{}).Where("ledger_id = ?", ledger.ID)
+	return applyEntryFilter(query, ledger.ID, filter)
+}
+
+// DeleteEntry 删除账单：移入回收站（软删除），附件保留到彻底删除时
+// 要求当前用户至少是账单所属账本的编辑者，且账本未归档；version 的含义同 UpdateEntry
+func (s *EntryService) DeleteEntry(ctx context.Context, id, userID uint, version *uint64) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkEntryVersion(tx, id, userID, version); err != nil {
+			return err
+		}
+		return removeEntry(tx, id, userID)
+	})
 }
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"math"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrGoalNotFound 储蓄目标不存在或无权访问
+var ErrGoalNotFound = errors.New("储蓄目标不存在或无权访问")
+
+const (
+	goalNameMaxRunes = 50
+	goalRateMonths   = 3       // 当前存入速度按最近几个月的平均值计算
+	daysPerMonth     = 30.4375 // 365.25 / 12，把天数换算为月数
+)
+
+type GoalService struct{}
+
+// CreateGoal 创建储蓄目标，goal.LedgerID 为空时使用当前账本，币种不填时使用创建人的本位币
+func (s *GoalService) CreateGoal(userID uint, goal *models.Goal) error {
+	ledger, err := writableLedger(goal.LedgerID, userID)
+	if err != nil {
+		return err
+	}
+	goal.LedgerID = ledger.ID
+	if err := validateGoal(goal); err != nil {
+		return err
+	}
+	if goal.Currency == "" {
+		if goal.Currency, err = baseCurrency(config.DB, userID); err != nil {
+			return err
+		}
+	}
+	goal.ID = 0
+	goal.UserID = userID
+	if err := config.DB.Create(goal).Error; err != nil {
+		return err
+	}
+	return fillGoalProgress(goal)
+}
+
+// ListGoals 查询账本中的储蓄目标及进度
+func (s *GoalService) ListGoals(userID uint, filter models.GoalFilter) ([]models.Goal, error) {
+	ledger, err := resolveLedger(filter.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	var goals []models.Goal
+	if err := config.DB.Where("ledger_id = ?", ledger.ID).Order("id DESC").Find(&goals).Error; err != nil {
+		return nil, err
+	}
+	for i := range goals {
+		if err := fillGoalProgress(&goals[i]); err != nil {
+			return nil, err
+		}
+	}
+	return goals, nil
+}
+
+// GetGoal 查询储蓄目标及进度
+func (s *GoalService) GetGoal(id, userID uint) (*models.Goal, error) {
+	goal, err := findGoal(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillGoalProgress(goal); err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// UpdateGoal 修改储蓄目标，所属账本和创建人不变；币种不填时保持原币种
+func (s *GoalService) UpdateGoal(id, userID uint, input *models.Goal) (*models.Goal, error) {
+	goal, err := findGoal(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writableLedger(goal.LedgerID, userID); err != nil {
+		return nil, err
+	}
+
+	goal.Name = input.Name
+	goal.TargetAmount = input.TargetAmount
+	if strings.TrimSpace(input.Currency) != "" {
+		goal.Currency = input.Currency
+	}
+	goal.StartDate = input.StartDate
+	goal.Deadline = input.Deadline
+	goal.AccountID = input.AccountID
+	goal.TagID = input.TagID
+	goal.Remark = input.Remark
+	if err := validateGoal(goal); err != nil {
+		return nil, err
+	}
+	err = config.DB.Model(goal).
+		Select("name", "target_amount", "currency", "start_date", "deadline", "account_id", "tag_id", "remark").
+		Updates(goal).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := fillGoalProgress(goal); err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// DeleteGoal 删除储蓄目标，账单不受影响
+func (s *GoalService) DeleteGoal(id, userID uint) error {
+	goal, err := findGoal(id, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := writableLedger(goal.LedgerID, userID); err != nil {
+		return err
+	}
+	return config.DB.Delete(goal).Error
+}
+
+// GoalEntries 分页查询储蓄目标相关的账单，按日期倒序
+func (s *GoalService) GoalEntries(id, userID uint, page, pageSize int) ([]models.LedgerEntry, int64, error) {
+	goal, err := findGoal(id, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	query, err := goalEntries(config.DB, goal, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.LedgerEntry
+	err = query.Preload("Tags").
+		Order("date DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// findGoal 查找当前用户所在账本中的储蓄目标
+func findGoal(id, userID uint) (*models.Goal, error) {
+	var goal models.Goal
+	if err := config.DB.Where("id = ? AND ledger_id IN (?)", id, memberLedgerIDs(userID)).First(&goal).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrGoalNotFound
+		}
+		return nil, err
+	}
+	return &goal, nil
+}
+
+// validateGoal 校验储蓄目标字段，关联的科目、标签必须属于目标所在账本
+func validateGoal(goal *models.Goal) error {
+	goal.Name = strings.TrimSpace(goal.Name)
+	goal.Remark = strings.TrimSpace(goal.Remark)
+	currency, err := checkCurrency(goal.Currency)
+	if err != nil {
+		return err
+	}
+	goal.Currency = currency
+
+	fields := utils.FieldErrors{}
+	if goal.Name == "" || len([]rune(goal.Name)) > goalNameMaxRunes {
+		fields["name"] = fmt.Sprintf("名称不能为空且不超过 %d 个字符", goalNameMaxRunes)
+	}
+	if utils.ToCents(goal.TargetAmount) <= 0 {
+		fields["target_amount"] = "目标金额必须大于 0"
+	}
+	if goal.StartDate != nil && goal.Deadline != nil && goal.Deadline.Before(*goal.StartDate) {
+		fields["deadline"] = "截止日期不能早于开始日期"
+	}
+	if len([]rune(goal.Remark)) > 255 {
+		fields["remark"] = "备注不能超过 255 个字符"
+	}
+	switch {
+	case (goal.AccountID == nil) == (goal.TagID == nil):
+		fields["account_id"] = "科目和标签必须关联一个且只能关联一个"
+	case goal.AccountID != nil:
+		accounts, err := ledgerAccounts(config.DB, goal.LedgerID)
+		if err != nil {
+			return err
+		}
+		fields["account_id"] = "科目不存在"
+		for _, account := range accounts {
+			if account.ID != *goal.AccountID {
+				continue
+			}
+			delete(fields, "account_id")
+			if account.Type != models.AccountTypeAsset {
+				fields["account_id"] = "只能关联资产科目"
+			}
+		}
+	default:
+		err := config.DB.Where("id = ? AND ledger_id = ?", *goal.TagID, goal.LedgerID).First(&models.Tag{}).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			fields["tag_id"] = "标签不存在"
+		case err != nil:
+			return err
+		}
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+	return nil
+}
+
+// goalEntries 限定为储蓄目标相关的账单：同一账本、同一币种、开始日期到 now 当天为止，
+// 并且资金账户对应关联的科目，或带有关联的标签；关联科目时进度另外包含手工凭证，见 goalAccountBalance
+func goalEntries(db *gorm.DB, goal *models.Goal, now time.Time) (*gorm.DB, error) {
+	query := db.Model(&models.LedgerEntry{}).
+		Where("ledger_id = ? AND currency = ? AND date < ?",
+			goal.LedgerID, goal.Currency, now.AddDate(0, 0, 1).Format(utils.DateLayout))
+	if goal.StartDate != nil {
+		query = query.Where("date >= ?", goal.StartDate.Format(utils.DateLayout))
+	}
+	if goal.TagID != nil {
+		return query.Where("id IN (SELECT ledger_entry_id FROM entry_tags WHERE tag_id = ?)", *goal.TagID), nil
+	}
+	var account models.Account
+	if goal.AccountID != nil {
+		if err := db.Select("id, name").First(&account, *goal.AccountID).Error; err != nil {
+			return nil, err
+		}
+	}
+	return whereEntryAccount(query, account.Name), nil
+}
+
+// goalAccountBalance 关联科目的目标在 from（为空时取开始日期）到 now 当天为止的余额变动（借方减贷方），单位为分
+// 与试算平衡表、净资产的口径一致，但只查询对应到该科目的记录，不汇总整个账本：
+// 资金账户为该科目的账单（收入加、支出减），科目为"应收借款"时借出、收回借款的对方分录，以及该科目的手工凭证分录
+func goalAccountBalance(goal *models.Goal, from string, now time.Time) (int64, error) {
+	var account models.Account
+	if err := config.DB.First(&account, *goal.AccountID).Error; err != nil {
+		return 0, err
+	}
+	if from == "" && goal.StartDate != nil {
+		from = goal.StartDate.Format(utils.DateLayout)
+	}
+	before := now.AddDate(0, 0, 1).Format(utils.DateLayout)
+	sum := func(query *gorm.DB, amount string) (float64, error) {
+		var total float64
+		err := query.Select("COALESCE(SUM(" + amount + "), 0)").Scan(&total).Error
+		return total, err
+	}
+	signed := fmt.Sprintf("CASE WHEN type = %d THEN amount ELSE -amount END", models.EntryTypeIncome)
+
+	entries := func() *gorm.DB {
+		query := config.DB.Model(&models.LedgerEntry{}).
+			Where("ledger_id = ? AND currency = ? AND date < ?", goal.LedgerID, goal.Currency, before)
+		if from != "" {
+			query = query.Where("date >= ?", from)
+		}
+		return query
+	}
+	total, err := sum(whereEntryAccount(entries(), account.Name), signed)
+	if err != nil {
+		return 0, err
+	}
+	if account.Name == bookReceivableAccount {
+		loans := entries().Where("loan_id IS NOT NULL AND category NOT IN ?", []string{loanCategoryBorrow, loanCategoryRepay})
+		lent, err := sum(loans, "-("+signed+")")
+		if err != nil {
+			return 0, err
+		}
+		total += lent
+	}
+
+	postings := config.DB.Table("postings").
+		Joins("JOIN journal_transactions ON journal_transactions.id = postings.transaction_id").
+		Where("postings.account_id = ? AND journal_transactions.ledger_id = ? AND journal_transactions.currency = ? AND journal_transactions.date < ?",
+			account.ID, goal.LedgerID, goal.Currency, before)
+	if from != "" {
+		postings = postings.Where("journal_transactions.date >= ?", from)
+	}
+	journal, err := sum(postings, "postings.debit - postings.credit")
+	if err != nil {
+		return 0, err
+	}
+	return utils.ToCents(total) + utils.ToCents(journal), nil
+}
+
+// fillGoalProgress 计算已存入金额、完成百分比、当前每月存入速度、截止日期前每月需存入的金额和预计达成日期
+// 当前速度为最近 goalRateMonths 个月（目标开始不足时从开始日期算起，至少按 1 个月）的平均每月存入
+func fillGoalProgress(goal *models.Goal) error {
+	today, _ := utils.ParseDate(time.Now().Format(utils.DateLayout))
+	windowStart := today.AddDate(0, -goalRateMonths, 0)
+	if goal.StartDate != nil && goal.StartDate.After(windowStart) {
+		windowStart = *goal.StartDate
+	}
+
+	sum := func(from string) (int64, error) {
+		if goal.AccountID != nil {
+			return goalAccountBalance(goal, from, today)
+		}
+		query, err := goalEntries(config.DB, goal, today)
+		if err != nil {
+			return 0, err
+		}
+		if from != "" {
+			query = query.Where("date >= ?", from)
+		}
+		// 带标签的收入计为存入，支出计为取出
+		amount := fmt.Sprintf("CASE WHEN type = %d THEN amount ELSE -amount END", models.EntryTypeIncome)
+		var total float64
+		if err := query.Select("COALESCE(SUM(" + amount + "), 0)").Scan(&total).Error; err != nil {
+			return 0, err
+		}
+		return utils.ToCents(total), nil
+	}
+	saved, err := sum("")
+	if err != nil {
+		return err
+	}
+	recent, err := sum(windowStart.Format(utils.DateLayout))
+	if err != nil {
+		return err
+	}
+
+	target := utils.ToCents(goal.TargetAmount)
+	remaining := max(target-saved, 0)
+	months := max(today.Sub(windowStart).Hours()/24/daysPerMonth, 1)
+	rate := int64(math.Round(float64(recent) / months))
+
+	goal.Saved = utils.FromCents(saved)
+	goal.Remaining = utils.FromCents(remaining)
+	goal.Percent = math.Round(float64(saved)*10000/float64(target)) / 100
+	goal.MonthlyRate = utils.FromCents(rate)
+	goal.RequiredMonthly = nil
+	goal.EstimatedDate = nil
+
+	if remaining == 0 {
+		goal.Status = models.GoalStatusAchieved
+		return nil
+	}
+	if rate > 0 {
+		days := int(math.Ceil(float64(remaining) / float64(rate) * daysPerMonth))
+		estimated := today.AddDate(0, 0, days)
+		goal.EstimatedDate = &estimated
+	}
+	switch {
+	case goal.Deadline == nil:
+		goal.Status = models.GoalStatusInProgress
+	case goal.Deadline.Format(utils.DateLayout) < today.Format(utils.DateLayout):
+		goal.Status = models.GoalStatusOverdue
+	default:
+		// 剩余不足一个月时按一个月计算，即本月需要存入全部差额
+		monthsLeft := max((goal.Deadline.Sub(today).Hours()/24+1)/daysPerMonth, 1)
+		required := utils.FromCents(int64(math.Ceil(float64(remaining) / monthsLeft)))
+		goal.RequiredMonthly = &required
+		goal.Status = models.GoalStatusBehind
+		if goal.EstimatedDate != nil && goal.EstimatedDate.Format(utils.DateLayout) <= goal.Deadline.Format(utils.DateLayout) {
+			goal.Status = models.GoalStatusOnTrack
+		}
+	}
+	return nil
+}
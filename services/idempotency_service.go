@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrIdempotencyMismatch 同一个 key 被用于内容不同的请求
+	ErrIdempotencyMismatch = errors.New("Idempotency-Key 已用于其他请求，请为新请求生成新的 key")
+	// ErrIdempotencyInProgress 相同 key 的请求仍在处理中
+	ErrIdempotencyInProgress = errors.New("相同 Idempotency-Key 的请求正在处理中，请稍后重试")
+)
+
+const (
+	defaultIdempotencyTTL           = 24 * time.Hour // 幂等记录的默认有效期，可在 config.yaml 的 idempotency.ttl 覆盖
+	defaultIdempotencyPurgeInterval = time.Hour      // 清理过期记录的执行间隔
+)
+
+type IdempotencyService struct{}
+
+// Begin 占用幂等 key
+// 返回 (nil, nil) 表示首次请求，调用方执行请求后调用 Complete 或 Release；
+// 返回已完成的记录表示重试，调用方直接返回记录中的响应
+func (s *IdempotencyService) Begin(userID uint, key, requestHash string) (*models.IdempotencyKey, error) {
+	now := time.Now()
+	// 顺带清理该用户已过期的记录，过期的 key 可以立即重新使用；其他用户的过期记录由 StartIdempotencyPurge 定期清理
+	if err := config.DB.Where("user_id = ? AND expires_at < ?", userID, now).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	record := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(utils.ConfigDuration("idempotency.ttl", defaultIdempotencyTTL)),
+	}
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := config.DB.Where("user_id = ? AND `key` = ?", userID, key).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 刚好被其他请求释放，按处理中返回，让客户端重试
+			return nil, ErrIdempotencyInProgress
+		}
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyMismatch
+	}
+	if existing.Status == 0 {
+		return nil, ErrIdempotencyInProgress
+	}
+	return &existing, nil
+}
+
+// Complete 保存请求的响应，有效期内的重试将直接返回该响应
+func (s *IdempotencyService) Complete(userID uint, key string, status int, contentType string, body []byte) error {
+	return config.DB.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND `key` = ?", userID, key).
+		Updates(map[string]interface{}{"status": status, "content_type": contentType, "body": body}).Error
+}
+
+// Release 释放幂等 key（请求失败且可以重试时），之后相同 key 的请求会重新执行
+func (s *IdempotencyService) Release(userID uint, key string) error {
+	return config.DB.Where("user_id = ? AND `key` = ?", userID, key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// PurgeExpired 删除所有用户在 now 之前过期的幂等记录，返回删除的数量
+func (s *IdempotencyService) PurgeExpired(now time.Time) (int64, error) {
+	result := config.DB.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// StartIdempotencyPurge 启动幂等记录清理：按 idempotency.purge_interval 定期删除所有用户已过期的记录
+// 不再发请求的用户的记录（最多含 1MB 响应体）不会被 Begin 顺带清理，需要在这里删除
+func StartIdempotencyPurge() {
+	interval := utils.ConfigDuration("idempotency.purge_interval", defaultIdempotencyPurgeInterval)
+	idempotency := new(IdempotencyService)
+
+	purge := func() {
+		purged, err := idempotency.PurgeExpired(time.Now())
+		if err != nil {
+			log.Printf("幂等记录清理失败: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("幂等记录清理: 删除 %d 条过期记录", purged)
+		}
+	}
+	go func() {
+		purge()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purge()
+		}
+	}()
+}
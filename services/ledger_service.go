@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLedgerNotFound 账本不存在或当前用户不是成员（两种情况不做区分，避免泄露账本是否存在）
+	ErrLedgerNotFound = errors.New("账本不存在或无权访问")
+	// ErrLedgerForbidden 是成员但角色权限不足
+	ErrLedgerForbidden = errors.New("当前角色无权执行该操作")
+	// ErrLedgerArchived 账本已归档，只读
+	ErrLedgerArchived = errors.New("账本已归档，不能修改")
+	// ErrInvitationNotFound 邀请不存在或已处理
+	ErrInvitationNotFound = errors.New("邀请不存在或已处理")
+	// ErrPeriodClosed 写入的日期落在账本已结账的期间内
+	ErrPeriodClosed = errors.New("账期已结账")
+)
+
+// roleRank 角色权限等级，数值越大权限越高
+var roleRank = map[string]int{
+	models.LedgerRoleViewer: 1,
+	models.LedgerRoleEditor: 2,
+	models.LedgerRoleOwner:  3,
+}
+
+type LedgerService struct{}
+
+// createLedger 在事务中创建账本，创建人自动成为所有者，并创建默认科目
+func createLedger(tx *gorm.DB, ownerID uint, name string) (*models.Ledger, error) {
+	ledger := models.Ledger{Name: name, OwnerID: ownerID}
+	if err := tx.Create(&ledger).Error; err != nil {
+		return nil, err
+	}
+	member := models.LedgerMember{LedgerID: ledger.ID, UserID: ownerID, Role: models.LedgerRoleOwner}
+	if err := tx.Create(&member).Error; err != nil {
+		return nil, err
+	}
+	if err := seedAccounts(tx, ledger.ID); err != nil {
+		return nil, err
+	}
+	return &ledger, nil
+}
+
+// CreateLedger 创建账本
+func (s *LedgerService) CreateLedger(userID uint, name string) (*models.Ledger, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, utils.FieldErrors{"name": "账本名称不能为空"}
+	}
+
+	var ledger *models.Ledger
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ledger, err = createLedger(tx, userID, name)
+		return err
+	})
+	return ledger, err
+}
+
+// ListLedgers 查询当前用户加入的账本，默认不包含已归档的账本
+func (s *LedgerService) ListLedgers(userID uint, includeArchived bool) ([]models.LedgerWithRole, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	query := config.DB.Model(&models.Ledger{}).
+		Select("ledgers.*, ledger_members.role").
+		Joins("JOIN ledger_members ON ledger_members.ledger_id = ledgers.id").
+		Where("ledger_members.user_id = ?", userID)
+	if !includeArchived {
+		query = query.Where("ledgers.archived_at IS NULL")
+	}
+
+	var ledgers []models.LedgerWithRole
+	if err := query.Order("ledgers.id").Scan(&ledgers).Error; err != nil {
+		return nil, err
+	}
+	for i := range ledgers {
+		ledgers[i].Current = ledgers[i].ID == user.CurrentLedgerID
+	}
+	return ledgers, nil
+}
+
+// RenameLedger 重命名账本，仅所有者可操作
+func (s *LedgerService) RenameLedger(ledgerID, userID uint, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return utils.FieldErrors{"name": "账本名称不能为空"}
+	}
+	ledger, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleOwner)
+	if err != nil {
+		return err
+	}
+	if ledger.ArchivedAt != nil {
+		return ErrLedgerArchived
+	}
+	return config.DB.Model(&models.Ledger{}).Where("id = ?", ledgerID).Update("name", name).Error
+}
+
+// ArchiveLedger 归档或取消归档账本，仅所有者可操作
+func (s *LedgerService) ArchiveLedger(ledgerID, userID uint, archived bool) error {
+	if _, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleOwner); err != nil {
+		return err
+	}
+	var archivedAt *time.Time
+	if archived {
+		now := time.Now()
+		archivedAt = &now
+	}
+	return config.DB.Model(&models.Ledger{}).Where("id = ?", ledgerID).Update("archived_at", archivedAt).Error
+}
+
+// SwitchLedger 切换当前账本，之后账单接口未指定 ledger_id 时使用该账本
+func (s *LedgerService) SwitchLedger(ledgerID, userID uint) error {
+	ledger, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+	if ledger.ArchivedAt != nil {
+		return ErrLedgerArchived
+	}
+	return config.DB.Model(&models.User{}).Where("id = ?", userID).Update("current_ledger_id", ledgerID).Error
+}
+
+// ClosePeriod 结账：before 之前的账单和记账凭证不能再新增、修改、删除，仅所有者可操作
+// 结账日期只能往后推，改为更早的日期需要通过 ReopenPeriod
+func (s *LedgerService) ClosePeriod(ctx context.Context, ledgerID, userID uint, before time.Time) (*models.Ledger, error) {
+	return setClosedBefore(ctx, ledgerID, userID, &before, true)
+}
+
+// ReopenPeriod 重新打开已结账的账期：结账日期改为更早的 before，before 为 nil 时取消结账，仅所有者可操作
+func (s *LedgerService) ReopenPeriod(ctx context.Context, ledgerID, userID uint, before *time.Time) (*models.Ledger, error) {
+	return setClosedBefore(ctx, ledgerID, userID, before, false)
+}
+
+// setClosedBefore 修改账本的结账日期并写入审计日志，closing 为 true 表示结账，否则为重新打开
+func setClosedBefore(ctx context.Context, ledgerID, userID uint, before *time.Time, closing bool) (*models.Ledger, error) {
+	role, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	if role.ArchivedAt != nil {
+		return nil, ErrLedgerArchived
+	}
+
+	var ledger models.Ledger
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ledger, ledgerID).Error; err != nil {
+			return err
+		}
+		current := formatClosedBefore(ledger.ClosedBefore)
+		next := formatClosedBefore(before)
+		action := models.AuditActionPeriodClose
+		if closing {
+			if ledger.ClosedBefore != nil && next <= current {
+				return utils.FieldErrors{"closed_before": "必须晚于当前的结账日期 " + current + "，改为更早的日期请重新打开账期"}
+			}
+		} else {
+			if ledger.ClosedBefore == nil {
+				return utils.FieldErrors{"closed_before": "账本没有结账"}
+			}
+			if before != nil && next >= current {
+				return utils.FieldErrors{"closed_before": "必须早于当前的结账日期 " + current}
+			}
+			action = models.AuditActionPeriodReopen
+		}
+
+		if err := tx.Model(&ledger).Update("closed_before", before).Error; err != nil {
+			return err
+		}
+		ledger.ClosedBefore = before
+		return writeAudit(tx, models.AuditLog{
+			Action:   action,
+			LedgerID: ledger.ID,
+			Detail:   fmt.Sprintf("结账日期 %s → %s", current, next),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ledger, nil
+}
+
+// formatClosedBefore 格式化结账日期，没有结账时为"无"
+func formatClosedBefore(date *time.Time) string {
+	if date == nil {
+		return "无"
+	}
+	return date.Format(utils.DateLayout)
+}
+
+// checkPeriodOpen 检查日期都不早于账本的结账日期，否则返回 ErrPeriodClosed
+// 所有写入账单、记账凭证的路径都应调用
+func checkPeriodOpen(tx *gorm.DB, ledgerID uint, dates ...time.Time) error {
+	var ledger models.Ledger
+	if err := tx.Select("id", "closed_before").First(&ledger, ledgerID).Error; err != nil {
+		return err
+	}
+	if ledger.ClosedBefore == nil {
+		return nil
+	}
+	closed := ledger.ClosedBefore.Format(utils.DateLayout)
+	for _, date := range dates {
+		if date.Format(utils.DateLayout) < closed {
+			return fmt.Errorf("%w：%s 之前的账单不能新增、修改或删除，日期 %s", ErrPeriodClosed, closed, date.Format(utils.DateLayout))
+		}
+	}
+	return nil
+}
+
+// checkEntriesPeriodOpen 检查账单（不含回收站中的）都不在所属账本已结账的期间内，用于批量改变账单状态的操作
+func checkEntriesPeriodOpen(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var entries []models.LedgerEntry
+	if err := tx.Select("id", "ledger_id", "date").Where("id IN ?", ids).Find(&entries).Error; err != nil {
+		return err
+	}
+	dates := make(map[uint][]time.Time)
+	for _, entry := range entries {
+		dates[entry.LedgerID] = append(dates[entry.LedgerID], entry.Date)
+	}
+	for ledgerID, ledgerDates := range dates {
+		if err := checkPeriodOpen(tx, ledgerID, ledgerDates...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLedgerRole 校验用户在账本中的角色不低于 minRole，返回账本及实际角色
+func checkLedgerRole(ledgerID, userID uint, minRole string) (*models.LedgerWithRole, error) {
+	var ledgers []models.LedgerWithRole
+	err := config.DB.Model(&models.Ledger{}).
+		Select("ledgers.*, ledger_members.role").
+		Joins("JOIN ledger_members ON ledger_members.ledger_id = ledgers.id").
+		Where("ledgers.id = ? AND ledger_members.user_id = ?", ledgerID, userID).
+		Limit(1).
+		Scan(&ledgers).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(ledgers) == 0 {
+		return nil, ErrLedgerNotFound
+	}
+	if roleRank[ledgers[0].Role] < roleRank[minRole] {
+		return nil, ErrLedgerForbidden
+	}
+	return &ledgers[0], nil
+}
+
+// resolveLedger 确定操作的账本：未指定时使用用户的当前账本，并校验角色
+func resolveLedger(ledgerID, userID uint, minRole string) (*models.LedgerWithRole, error) {
+	if ledgerID == 0 {
+		var user models.User
+		if err := config.DB.First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+		if user.CurrentLedgerID == 0 {
+			return nil, ErrLedgerNotFound
+		}
+		ledgerID = user.CurrentLedgerID
+	}
+	return checkLedgerRole(ledgerID, userID, minRole)
+}
+
+// writableLedger 确定要写入的账本：至少是编辑者，且账本未归档
+func writableLedger(ledgerID, userID uint) (*models.LedgerWithRole, error) {
+	ledger, err := resolveLedger(ledgerID, userID, models.LedgerRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if ledger.ArchivedAt != nil {
+		return nil, ErrLedgerArchived
+	}
+	return ledger, nil
+}
+
+// memberLedgerIDs 返回用户加入的账本 ID 子查询，供其他查询按成员关系限定范围
+func memberLedgerIDs(userID uint) *gorm.DB {
+	return config.DB.Model(&models.LedgerMember{}).Select("ledger_id").Where("user_id = ?", userID)
+}
+
+// ListMembers 查询账本成员，任意成员可查看
+func (s *LedgerService) ListMembers(ledgerID, userID uint) ([]models.LedgerMemberInfo, error) {
+	if _, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleViewer); err != nil {
+		return nil, err
+	}
+
+	var members []models.LedgerMemberInfo
+	err := config.DB.Model(&models.LedgerMember{}).
+		Select("ledger_members.user_id, users.username, ledger_members.role, ledger_members.created_at").
+		Joins("JOIN users ON users.id = ledger_members.user_id").
+		Where("ledger_members.ledger_id = ?", ledgerID).
+		Order("ledger_members.id").
+		Scan(&members).Error
+	return members, err
+}
+
+// Invite 按用户名邀请成员，仅所有者可操作
+func (s *LedgerService) Invite(ledgerID, inviterID uint, username, role string) (*models.LedgerInvitation, error) {
+	if _, err := checkLedgerRole(ledgerID, inviterID, models.LedgerRoleOwner); err != nil {
+		return nil, err
+	}
+	if role != models.LedgerRoleEditor && role != models.LedgerRoleViewer {
+		return nil, utils.FieldErrors{"role": "角色只能是 editor 或 viewer"}
+	}
+
+	var invitee models.User
+	if err := config.DB.Where("username = ?", username).First(&invitee).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.FieldErrors{"username": "用户不存在"}
+		}
+		return nil, err
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.LedgerMember{}).
+		Where("ledger_id = ? AND user_id = ?", ledgerID, invitee.ID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, utils.FieldErrors{"username": "该用户已是账本成员"}
+	}
+
+	if err := config.DB.Model(&models.LedgerInvitation{}).
+		Where("ledger_id = ? AND invitee_id = ? AND status = ?", ledgerID, invitee.ID, models.InvitationStatusPending).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, utils.FieldErrors{"username": "已向该用户发出邀请，等待对方处理"}
+	}
+
+	invitation := models.LedgerInvitation{
+		LedgerID:  ledgerID,
+		InviterID: inviterID,
+		InviteeID: invitee.ID,
+		Role:      role,
+		Status:    models.InvitationStatusPending,
+	}
+	if err := config.DB.Create(&invitation).Error; err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListInvitations 查询发给当前用户的待处理邀请
+func (s *LedgerService) ListInvitations(userID uint) ([]models.InvitationInfo, error) {
+	var invitations []models.InvitationInfo
+	err := config.DB.Model(&models.LedgerInvitation{}).
+		Select("ledger_invitations.id, ledger_invitations.ledger_id, ledgers.name AS ledger_name, users.username AS inviter_username, ledger_invitations.role, ledger_invitations.created_at").
+		Joins("JOIN ledgers ON ledgers.id = ledger_invitations.ledger_id AND ledgers.deleted_at IS NULL").
+		Joins("JOIN users ON users.id = ledger_invitations.inviter_id").
+		Where("ledger_invitations.invitee_id = ? AND ledger_invitations.status = ?", userID, models.InvitationStatusPending).
+		Order("ledger_invitations.id desc").
+		Scan(&invitations).Error
+	return invitations, err
+}
+
+// RespondInvitation 接受或拒绝邀请，接受后加入账本
+func (s *LedgerService) RespondInvitation(invitationID, userID uint, accept bool) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var invitation models.LedgerInvitation
+		err := tx.Where("id = ? AND invitee_id = ? AND status = ?", invitationID, userID, models.InvitationStatusPending).
+			First(&invitation).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvitationNotFound
+			}
+			return err
+		}
+
+		status := models.InvitationStatusDeclined
+		if accept {
+			status = models.InvitationStatusAccepted
+		}
+		// 带上状态条件更新，防止并发重复处理
+		result := tx.Model(&invitation).Where("status = ?", models.InvitationStatusPending).Update("status", status)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationNotFound
+		}
+		if !accept {
+			return nil
+		}
+
+		member := models.LedgerMember{LedgerID: invitation.LedgerID, UserID: userID, Role: invitation.Role}
+		return tx.Create(&member).Error
+	})
+}
+
+// UpdateMemberRole 修改成员角色，仅所有者可操作，所有者本人的角色不能修改
+func (s *LedgerService) UpdateMemberRole(ledgerID, ownerID, memberID uint, role string) error {
+	if _, err := checkLedgerRole(ledgerID, ownerID, models.LedgerRoleOwner); err != nil {
+		return err
+	}
+	if role != models.LedgerRoleEditor && role != models.LedgerRoleViewer {
+		return utils.FieldErrors{"role": "角色只能是 editor 或 viewer"}
+	}
+	if memberID == ownerID {
+		return utils.FieldErrors{"user_id": "不能修改所有者的角色"}
+	}
+
+	result := config.DB.Model(&models.LedgerMember{}).
+		Where("ledger_id = ? AND user_id = ?", ledgerID, memberID).
+		Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return utils.FieldErrors{"user_id": "该用户不是账本成员"}
+	}
+	return nil
+}
+
+// RemoveMember 移除成员：所有者可以移除其他成员，成员可以移除自己（退出账本）
+func (s *LedgerService) RemoveMember(ledgerID, actorID, memberID uint) error {
+	minRole := models.LedgerRoleOwner
+	if actorID == memberID {
+		minRole = models.LedgerRoleViewer
+	}
+	ledger, err := checkLedgerRole(ledgerID, actorID, minRole)
+	if err != nil {
+		return err
+	}
+	if actorID == memberID && ledger.Role == models.LedgerRoleOwner {
+		return utils.FieldErrors{"user_id": "所有者不能退出自己的账本"}
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("ledger_id = ? AND user_id = ?", ledgerID, memberID).Delete(&models.LedgerMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return utils.FieldErrors{"user_id": "该用户不是账本成员"}
+		}
+		// 被移除的账本如果是对方的当前账本，切回其最早创建的账本（即默认账本）
+		return tx.Model(&models.User{}).
+			Where("id = ? AND current_ledger_id = ?", memberID, ledgerID).
+			Update("current_ledger_id", tx.Model(&models.Ledger{}).Select("MIN(id)").Where("owner_id = ?", memberID)).Error
+	})
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLoanNotFound 借贷记录不存在或无权访问
+var ErrLoanNotFound = errors.New("借贷记录不存在或无权访问")
+
+// 借贷产生的账单使用的分类
+const (
+	loanCategoryLend         = "借出"
+	loanCategoryBorrow       = "借入"
+	loanCategoryCollect      = "收回借款"
+	loanCategoryRepay        = "偿还借款"
+	loanEntryRemarkMaxRunes  = 255
+	loanCounterpartyMaxRunes = 50
+)
+
+type LoanService struct{}
+
+// CreateLoan 创建借贷记录，同时生成本金对应的账单（借出记支出，借入记收入）
+func (s *LoanService) CreateLoan(ctx context.Context, userID uint, loan *models.Loan) error {
+	ledger, err := writableLedger(loan.LedgerID, userID)
+	if err != nil {
+		return err
+	}
+
+	loan.Counterparty = strings.TrimSpace(loan.Counterparty)
+	fields := utils.FieldErrors{}
+	if loan.Direction != models.LoanDirectionLend && loan.Direction != models.LoanDirectionBorrow {
+		fields["direction"] = "方向只能是 lend 或 borrow"
+	}
+	if loan.Counterparty == "" || len([]rune(loan.Counterparty)) > loanCounterpartyMaxRunes {
+		fields["counterparty"] = fmt.Sprintf("对方名称不能为空且不超过 %d 个字符", loanCounterpartyMaxRunes)
+	}
+	if utils.ToCents(loan.Principal) <= 0 {
+		fields["principal"] = "本金必须大于 0"
+	}
+	if loan.DueDate != nil && loan.DueDate.Before(loan.Date) {
+		fields["due_date"] = "到期日不能早于借款日期"
+	}
+	if len(fields) > 0 {
+		return fields
+	}
+	if loan.Currency, err = checkCurrency(loan.Currency); err != nil {
+		return err
+	}
+	if loan.Currency == "" {
+		if loan.Currency, err = baseCurrency(config.DB, userID); err != nil {
+			return err
+		}
+	}
+
+	loan.LedgerID = ledger.ID
+	loan.UserID = userID
+	loan.Status = models.LoanStatusOpen
+
+	entry := models.LedgerEntry{
+		UserID:   userID,
+		LedgerID: ledger.ID,
+		Type:     models.EntryTypeExpense,
+		Amount:   loan.Principal,
+		Currency: loan.Currency,
+		Category: loanCategoryLend,
+		Date:     loan.Date,
+		Remark:   loanRemark(loan.Counterparty, loan.Remark),
+	}
+	if loan.Direction == models.LoanDirectionBorrow {
+		entry.Type = models.EntryTypeIncome
+		entry.Category = loanCategoryBorrow
+	}
+
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Repayments").Create(loan).Error; err != nil {
+			return err
+		}
+		entry.LoanID = &loan.ID
+		if err := createEntry(tx, &entry); err != nil {
+			return err
+		}
+		loan.EntryID = entry.ID
+		return tx.Model(loan).Update("entry_id", entry.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	fillLoanBalance(loan)
+	return nil
+}
+
+// ListLoans 查询账本中的借贷记录
+func (s *LoanService) ListLoans(userID uint, filter models.LoanFilter) ([]models.Loan, error) {
+	ledger, err := resolveLedger(filter.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	query := config.DB.Where("ledger_id = ?", ledger.ID)
+	if filter.Direction != "" {
+		query = query.Where("direction = ?", filter.Direction)
+	}
+	today := time.Now().Format("2006-01-02")
+	switch filter.Status {
+	case "":
+	case models.LoanStatusOpen, models.LoanStatusSettled:
+		query = query.Where("status = ?", filter.Status)
+	case models.LoanStatusOverdue:
+		query = query.Where("status = ? AND due_date < ?", models.LoanStatusOpen, today)
+	default:
+		return nil, utils.FieldErrors{"status": "状态只能是 open、settled 或 overdue"}
+	}
+
+	var loans []models.Loan
+	if err := query.Preload("Repayments").Order("date desc, id desc").Find(&loans).Error; err != nil {
+		return nil, err
+	}
+	for i := range loans {
+		fillLoanBalance(&loans[i])
+	}
+	return loans, nil
+}
+
+// GetLoan 查询单条借贷记录及还款历史
+func (s *LoanService) GetLoan(id, userID uint) (*models.Loan, error) {
+	var loan models.Loan
+	err := config.DB.Where("id = ? AND ledger_id IN (?)", id, memberLedgerIDs(userID)).
+		Preload("Repayments", func(db *gorm.DB) *gorm.DB { return db.Order("date, id") }).
+		First(&loan).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoanNotFound
+		}
+		return nil, err
+	}
+	fillLoanBalance(&loan)
+	return &loan, nil
+}
+
+// AddRepayment 登记一笔还款，同时生成对应的账单；还清后状态变为 settled
+func (s *LoanService) AddRepayment(ctx context.Context, loanID, userID uint, repayment *models.LoanRepayment) (*models.Loan, error) {
+	loan, err := s.GetLoan(loanID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writableLedger(loan.LedgerID, userID); err != nil {
+		return nil, err
+	}
+
+	amount := utils.ToCents(repayment.Amount)
+	if amount <= 0 {
+		return nil, utils.FieldErrors{"amount": "还款金额必须大于 0"}
+	}
+	if outstanding := utils.ToCents(loan.Outstanding); amount > outstanding {
+		return nil, utils.FieldErrors{"amount": fmt.Sprintf("还款金额不能超过未还金额 %.2f", loan.Outstanding)}
+	}
+	if repayment.Date.Before(loan.Date) {
+		return nil, utils.FieldErrors{"date": "还款日期不能早于借款日期"}
+	}
+
+	entry := models.LedgerEntry{
+		UserID:   userID,
+		LedgerID: loan.LedgerID,
+		Type:     models.EntryTypeIncome,
+		Amount:   repayment.Amount,
+		Currency: loan.Currency,
+		Category: loanCategoryCollect,
+		Date:     repayment.Date,
+		Remark:   loanRemark(loan.Counterparty, repayment.Remark),
+		LoanID:   &loan.ID,
+	}
+	if loan.Direction == models.LoanDirectionBorrow {
+		entry.Type = models.EntryTypeExpense
+		entry.Category = loanCategoryRepay
+	}
+
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 锁住借贷记录后重新计算已还金额，防止并发还款超出本金
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Loan{}, loan.ID).Error; err != nil {
+			return err
+		}
+		var repaid float64
+		if err := tx.Model(&models.LoanRepayment{}).
+			Where("loan_id = ?", loan.ID).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&repaid).Error; err != nil {
+			return err
+		}
+		if utils.ToCents(repaid)+amount > utils.ToCents(loan.Principal) {
+			return utils.FieldErrors{"amount": "还款金额超过未还金额"}
+		}
+
+		if err := createEntry(tx, &entry); err != nil {
+			return err
+		}
+		repayment.LoanID = loan.ID
+		repayment.EntryID = entry.ID
+		if err := tx.Create(repayment).Error; err != nil {
+			return err
+		}
+		if utils.ToCents(repaid)+amount == utils.ToCents(loan.Principal) {
+			return tx.Model(&models.Loan{}).Where("id = ?", loan.ID).Update("status", models.LoanStatusSettled).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetLoan(loanID, userID)
+}
+
+// DeleteLoan 删除借贷记录及其本金、还款对应的账单
+func (s *LoanService) DeleteLoan(ctx context.Context, id, userID uint) error {
+	loan, err := s.GetLoan(id, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := writableLedger(loan.LedgerID, userID); err != nil {
+		return err
+	}
+
+	var files []string
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var entryIDs []uint
+		if err := tx.Model(&models.LedgerEntry{}).Where("loan_id = ?", loan.ID).Pluck("id", &entryIDs).Error; err != nil {
+			return err
+		}
+		if _, err := deleteEntries(tx, entryIDs); err != nil {
+			return err
+		}
+		var err error
+		if files, err = deleteEntryAttachments(tx, entryIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("loan_id = ?", loan.ID).Delete(&models.LoanRepayment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Loan{}, loan.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	removeFiles(files)
+	return nil
+}
+
+// fillLoanBalance 根据还款记录计算已还、未还金额，并把到期未还的记录标记为 overdue
+func fillLoanBalance(loan *models.Loan) {
+	var repaid int64
+	for _, r := range loan.Repayments {
+		repaid += utils.ToCents(r.Amount)
+	}
+	loan.Repaid = utils.FromCents(repaid)
+	loan.Outstanding = utils.FromCents(utils.ToCents(loan.Principal) - repaid)
+
+	if loan.Status == models.LoanStatusOpen && loan.DueDate != nil {
+		today := time.Now().Format("2006-01-02")
+		if loan.DueDate.Format("2006-01-02") < today {
+			loan.Status = models.LoanStatusOverdue
+		}
+	}
+}
+
+// loanRemark 生成借贷账单的备注，带上对方名称
+func loanRemark(counterparty, remark string) string {
+	text := strings.TrimSpace(counterparty + " " + remark)
+	if runes := []rune(text); len(runes) > loanEntryRemarkMaxRunes {
+		text = string(runes[:loanEntryRemarkMaxRunes])
+	}
+	return text
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"go-ledger/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 登录限流默认配置，可在 config.yaml 的 auth.login 下覆盖
+const (
+	defaultMaxFailuresPerUser = 5                // 同一用户名连续失败多少次后锁定
+	defaultMaxFailuresPerIP   = 20               // 同一 IP 连续失败多少次后锁定
+	defaultLockoutBase        = time.Minute      // 首次锁定时长，之后每次翻倍
+	defaultLockoutMax         = time.Hour        // 锁定时长上限
+	defaultFailureWindow      = 15 * time.Minute // 超过该时间没有新的失败则清零计数
+
+	maxTrackedKeys = 10000 // 记录数超过该值时清理过期记录
+)
+
+// loginAttempt 记录某个 key（用户名或 IP）的失败情况
+type loginAttempt struct {
+	failures    int       // 当前窗口内的连续失败次数
+	lockouts    int       // 已触发的锁定次数，用于计算指数退避
+	lastFailure time.Time // 最近一次失败时间
+	lockedUntil time.Time // 锁定截止时间
+}
+
+// loginLimiter 按用户名和客户端 IP 统计登录失败次数（进程内存储，重启后清零）
+type loginLimiter struct {
+	mu       sync.Mutex
+	attempts map[string]*loginAttempt
+}
+
+var limiter = &loginLimiter{attempts: make(map[string]*loginAttempt)}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// locked 判断用户名或 IP 是否处于锁定状态
+func (l *loginLimiter) locked(username, ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		if a, ok := l.attempts[key]; ok && now.Before(a.lockedUntil) {
+			return true
+		}
+	}
+	return false
+}
+
+// lockEvent 描述一次新触发的锁定，供调用方写审计日志
+type lockEvent struct {
+	Scope    string // user 或 ip
+	Failures int
+	Duration time.Duration
+}
+
+// fail 记录一次失败，返回本次新触发的锁定（没有触发则为空）
+func (l *loginLimiter) fail(username, ip string) []lockEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.attempts) > maxTrackedKeys {
+		l.prune()
+	}
+
+	var events []lockEvent
+	if e := l.failKey(userKey(username), utils.ConfigInt("auth.login.max_failures_per_user", defaultMaxFailuresPerUser)); e != nil {
+		e.Scope = "user"
+		events = append(events, *e)
+	}
+	if e := l.failKey(ipKey(ip), utils.ConfigInt("auth.login.max_failures_per_ip", defaultMaxFailuresPerIP)); e != nil {
+		e.Scope = "ip"
+		events = append(events, *e)
+	}
+	return events
+}
+
+func (l *loginLimiter) failKey(key string, maxFailures int) *lockEvent {
+	now := time.Now()
+	a, ok := l.attempts[key]
+	if !ok {
+		a = &loginAttempt{}
+		l.attempts[key] = a
+	}
+
+	// 窗口期内没有新的失败，重新计数（锁定次数保留，用于退避）
+	if now.Sub(a.lastFailure) > utils.ConfigDuration("auth.login.failure_window", defaultFailureWindow) {
+		a.failures = 0
+	}
+	a.failures++
+	a.lastFailure = now
+
+	if a.failures < maxFailures {
+		return nil
+	}
+
+	// 指数退避：base * 2^lockouts，不超过上限
+	base := utils.ConfigDuration("auth.login.lockout_base", defaultLockoutBase)
+	max := utils.ConfigDuration("auth.login.lockout_max", defaultLockoutMax)
+	duration := base << a.lockouts
+	if duration > max || duration <= 0 {
+		duration = max
+	}
+	a.lockouts++
+	a.lockedUntil = now.Add(duration)
+
+	failures := a.failures
+	a.failures = 0
+	return &lockEvent{Failures: failures, Duration: duration}
+}
+
+// prune 清理已过期的记录，防止大量随机用户名把内存撑大
+func (l *loginLimiter) prune() {
+	now := time.Now()
+	window := utils.ConfigDuration("auth.login.failure_window", defaultFailureWindow)
+	for key, a := range l.attempts {
+		if now.After(a.lockedUntil) && now.Sub(a.lastFailure) > window {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+// succeed 登录成功后清除该用户名的失败记录
+// IP 计数不清除，避免攻击者用自己的账号登录来重置 IP 维度的计数
+func (l *loginLimiter) succeed(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, userKey(username))
+}
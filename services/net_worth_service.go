@@ -0,0 +1,376 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNetWorthSnapshotNotFound 指定日期及之前没有净资产快照
+var ErrNetWorthSnapshotNotFound = errors.New("还没有净资产快照")
+
+const (
+	defaultNetWorthSnapshotInterval = 6 * time.Hour
+	netWorthSnapshotBatchSize       = 100
+)
+
+type NetWorthService struct{}
+
+// TakeSnapshot 立即生成当前用户的净资产快照，date 为空时为今天，不能晚于今天
+func (s *NetWorthService) TakeSnapshot(userID uint, date string) (*models.NetWorthBreakdown, error) {
+	today, _ := utils.ParseDate(time.Now().Format(utils.DateLayout))
+	day := today
+	if date != "" {
+		fields := utils.FieldErrors{}
+		parsed, ok := parseDateParam(date, "date", fields)
+		if !ok {
+			return nil, fields
+		}
+		if parsed.After(today) {
+			return nil, utils.FieldErrors{"date": "不能晚于今天"}
+		}
+		day = parsed
+	}
+	fingerprint, err := netWorthFingerprint(userID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := takeNetWorthSnapshot(userID, day, fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return netWorthBreakdown(snapshot), nil
+}
+
+// GetBreakdown 返回 date（为空时不限）当天或之前最近一份快照的资产、负债明细
+func (s *NetWorthService) GetBreakdown(userID uint, date string) (*models.NetWorthBreakdown, error) {
+	query := config.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id") // 保存时已按账本、类型、科目编码排序
+	}).Where("user_id = ?", userID)
+	if date != "" {
+		fields := utils.FieldErrors{}
+		day, ok := parseDateParam(date, "date", fields)
+		if !ok {
+			return nil, fields
+		}
+		query = query.Where("date <= ?", day.Format(utils.DateLayout))
+	}
+	var snapshot models.NetWorthSnapshot
+	if err := query.Order("date DESC").First(&snapshot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNetWorthSnapshotNotFound
+		}
+		return nil, err
+	}
+	return netWorthBreakdown(&snapshot), nil
+}
+
+// History 按日期正序返回净资产走势，interval 为 month 时每月取最后一份快照
+func (s *NetWorthService) History(userID uint, filter models.NetWorthFilter) ([]models.NetWorthPoint, error) {
+	dates, err := parseReportDates(models.ReportFilter{StartDate: filter.StartDate, EndDate: filter.EndDate})
+	if err != nil {
+		return nil, err
+	}
+	interval := filter.Interval
+	if interval == "" {
+		interval = models.NetWorthIntervalDay
+	}
+	if interval != models.NetWorthIntervalDay && interval != models.NetWorthIntervalMonth {
+		return nil, utils.FieldErrors{"interval": "只能为 day 或 month"}
+	}
+
+	query := config.DB.Where("user_id = ?", userID)
+	if dates.from != "" {
+		query = query.Where("date >= ?", dates.from)
+	}
+	if dates.before != "" {
+		query = query.Where("date < ?", dates.before)
+	}
+	var snapshots []models.NetWorthSnapshot
+	if err := query.Order("date").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	points := make([]models.NetWorthPoint, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		point := models.NetWorthPoint{
+			Date:             snapshot.Date.Format(utils.DateLayout),
+			BaseCurrency:     snapshot.BaseCurrency,
+			TotalAssets:      snapshot.TotalAssets,
+			TotalLiabilities: snapshot.TotalLiabilities,
+			NetWorth:         snapshot.NetWorth,
+		}
+		if interval == models.NetWorthIntervalMonth {
+			point.Date = snapshot.Date.Format("2006-01")
+			// 快照按日期正序，同一个月的后一份覆盖前一份
+			if n := len(points); n > 0 && points[n-1].Date == point.Date {
+				points[n-1] = point
+				continue
+			}
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// SnapshotAll 为所有用户生成 day 当天的净资产快照，单个用户失败（如缺少汇率）时记录日志并继续，返回生成数量
+// 当天已有快照且之后账单、凭证、科目、汇率都没有变化的用户跳过，不重新统计
+func (s *NetWorthService) SnapshotAll(day time.Time) (int, error) {
+	taken := 0
+	var lastID uint
+	for {
+		var userIDs []uint
+		err := config.DB.Model(&models.User{}).Where("id > ?", lastID).
+			Order("id").Limit(netWorthSnapshotBatchSize).Pluck("id", &userIDs).Error
+		if err != nil {
+			return taken, err
+		}
+		if len(userIDs) == 0 {
+			return taken, nil
+		}
+		for _, userID := range userIDs {
+			fingerprint, err := netWorthFingerprint(userID)
+			if err != nil {
+				log.Printf("用户 %d 的净资产快照生成失败: %v", userID, err)
+				continue
+			}
+			var unchanged int64
+			err = config.DB.Model(&models.NetWorthSnapshot{}).
+				Where("user_id = ? AND date >= ? AND date < ? AND fingerprint = ?",
+					userID, day.Format(utils.DateLayout), day.AddDate(0, 0, 1).Format(utils.DateLayout), fingerprint).
+				Count(&unchanged).Error
+			if err != nil {
+				return taken, err
+			}
+			if unchanged > 0 {
+				continue
+			}
+			if _, err := takeNetWorthSnapshot(userID, day, fingerprint); err != nil {
+				log.Printf("用户 %d 的净资产快照生成失败: %v", userID, err)
+				continue
+			}
+			taken++
+		}
+		lastID = userIDs[len(userIDs)-1]
+	}
+}
+
+// netWorthFingerprint 汇总影响用户净资产的数据版本：本位币、拥有的账本及其账单变更序号、凭证、科目和汇率
+// 任何一项变化都会得到不同的结果，只用于判断当天的快照是否需要重新生成
+func netWorthFingerprint(userID uint) (string, error) {
+	base, err := baseCurrency(config.DB, userID)
+	if err != nil {
+		return "", err
+	}
+	var ledgers []models.Ledger
+	if err := config.DB.Select("id, sync_seq").Where("owner_id = ?", userID).Order("id").Find(&ledgers).Error; err != nil {
+		return "", err
+	}
+	ledgerIDs := make([]uint, len(ledgers))
+	for i, ledger := range ledgers {
+		ledgerIDs[i] = ledger.ID
+	}
+
+	// 凭证和汇率删除时物理删除，因此同时比较数量和最大 ID
+	type version struct {
+		Count  int64
+		MaxID  uint
+		Latest string
+	}
+	const versionColumns = "COUNT(*) AS count, COALESCE(MAX(id), 0) AS max_id, COALESCE(MAX(updated_at), '') AS latest"
+	var journals, accounts, rates version
+	if err := config.DB.Table("journal_transactions").Where("ledger_id IN ?", ledgerIDs).
+		Select(versionColumns).Scan(&journals).Error; err != nil {
+		return "", err
+	}
+	if err := config.DB.Table("accounts").Where("ledger_id IN ?", ledgerIDs).
+		Select(versionColumns).Scan(&accounts).Error; err != nil {
+		return "", err
+	}
+	if err := config.DB.Table("exchange_rates").Where("user_id = ?", userID).
+		Select(versionColumns).Scan(&rates).Error; err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s|", base)
+	for _, ledger := range ledgers {
+		fmt.Fprintf(hash, "%d:%d,", ledger.ID, ledger.SyncSeq)
+	}
+	fmt.Fprintf(hash, "|%v|%v|%v", journals, accounts, rates)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// takeNetWorthSnapshot 统计用户拥有的所有账本截至 day 当天的资产、负债科目余额，换算为本位币后保存为快照
+// 同一天已有快照时覆盖；只统计本人是所有者的账本，共享给本人的账本计入所有者的净资产
+// fingerprint 为统计前的 netWorthFingerprint，随快照保存
+func takeNetWorthSnapshot(userID uint, day time.Time, fingerprint string) (*models.NetWorthSnapshot, error) {
+	converter, err := newRateConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+	var ledgerIDs []uint
+	if err := config.DB.Model(&models.Ledger{}).Where("owner_id = ?", userID).Order("id").Pluck("id", &ledgerIDs).Error; err != nil {
+		return nil, err
+	}
+
+	snapshot := &models.NetWorthSnapshot{UserID: userID, Date: day, BaseCurrency: converter.base, Fingerprint: fingerprint}
+	dates := dateRange{before: day.AddDate(0, 0, 1).Format(utils.DateLayout)}
+	var assets, liabilities int64
+	for _, ledgerID := range ledgerIDs {
+		book, err := revaluedBook(converter, ledgerID, dates, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, balance := range book.balances(models.AccountTypeAsset, models.AccountTypeLiability) {
+			cents := utils.ToCents(balance.Closing)
+			if cents == 0 {
+				continue
+			}
+			if balance.Type == models.AccountTypeAsset {
+				assets += cents
+			} else {
+				liabilities += cents
+			}
+			snapshot.Items = append(snapshot.Items, models.NetWorthItem{
+				LedgerID:  ledgerID,
+				AccountID: balance.AccountID,
+				Code:      balance.Code,
+				Name:      balance.Name,
+				Type:      balance.Type,
+				Balance:   balance.Closing,
+			})
+		}
+	}
+	snapshot.TotalAssets = utils.FromCents(assets)
+	snapshot.TotalLiabilities = utils.FromCents(liabilities)
+	snapshot.NetWorth = utils.FromCents(assets - liabilities)
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁住用户，避免定时任务和手动生成同时写入同一天的快照
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, userID).Error; err != nil {
+			return err
+		}
+		var oldIDs []uint
+		err := tx.Model(&models.NetWorthSnapshot{}).
+			Where("user_id = ? AND date >= ? AND date < ?", userID, day.Format(utils.DateLayout), dates.before).
+			Pluck("id", &oldIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(oldIDs) > 0 {
+			if err := tx.Where("snapshot_id IN ?", oldIDs).Delete(&models.NetWorthItem{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.NetWorthSnapshot{}, oldIDs).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(snapshot).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// revaluedBook 按币种分别汇总账本的科目余额，再把各币种的期末余额按 day 当天的汇率换算为 converter 的本位币
+// 外币余额按快照当天的汇率重估，而不是按每笔账单发生时的汇率累计
+func revaluedBook(converter *rateConverter, ledgerID uint, dates dateRange, day time.Time) (*bookTotals, error) {
+	currencies := make(map[string]bool)
+	for _, table := range []string{"ledger_entries", "journal_transactions"} {
+		query := config.DB.Table(table).Where("ledger_id = ? AND date < ?", ledgerID, dates.before)
+		if table == "ledger_entries" {
+			query = query.Where("deleted_at IS NULL")
+		}
+		var list []string
+		if err := query.Distinct().Pluck("currency", &list).Error; err != nil {
+			return nil, err
+		}
+		for _, currency := range list {
+			currencies[currency] = true
+		}
+	}
+
+	revalued := &bookTotals{base: converter.base, accounts: make(map[bookAccount]*accountTotals)}
+	for currency := range currencies {
+		// 只统计该币种时按原币汇总，不需要汇率
+		original := &rateConverter{userID: converter.userID, base: currency, cache: make(map[string]float64)}
+		book, err := collectLedgerBook(original, ledgerID, dates, false, currency)
+		if err != nil {
+			return nil, err
+		}
+		for account, totals := range book.accounts {
+			if account.Type != models.AccountTypeAsset && account.Type != models.AccountTypeLiability {
+				continue
+			}
+			net := totals.debit - totals.credit
+			if net == 0 {
+				continue
+			}
+			converted, err := converter.convert(utils.FromCents(net), currency, day)
+			if err != nil {
+				return nil, err
+			}
+			cents := utils.ToCents(converted)
+			revalued.post(account, day, max(cents, 0), max(-cents, 0))
+		}
+	}
+	return revalued, nil
+}
+
+// netWorthBreakdown 把快照明细按资产、负债分组
+func netWorthBreakdown(snapshot *models.NetWorthSnapshot) *models.NetWorthBreakdown {
+	breakdown := &models.NetWorthBreakdown{
+		NetWorthSnapshot: *snapshot,
+		Assets:           []models.NetWorthItem{},
+		Liabilities:      []models.NetWorthItem{},
+	}
+	for _, item := range snapshot.Items {
+		if item.Type == models.AccountTypeAsset {
+			breakdown.Assets = append(breakdown.Assets, item)
+		} else {
+			breakdown.Liabilities = append(breakdown.Liabilities, item)
+		}
+	}
+	return breakdown
+}
+
+// StartNetWorthSnapshots 启动净资产自动快照：按 net_worth.snapshot_interval 定期为所有用户生成当天的快照
+// 同一天多次生成时覆盖，当天的快照反映当天最后一次执行时的余额，当天已生成且数据没有变化的用户跳过；net_worth.auto_snapshot 设为 false 时不启动
+func StartNetWorthSnapshots() {
+	if viper.IsSet("net_worth.auto_snapshot") && !viper.GetBool("net_worth.auto_snapshot") {
+		return
+	}
+	interval := utils.ConfigDuration("net_worth.snapshot_interval", defaultNetWorthSnapshotInterval)
+	netWorth := new(NetWorthService)
+
+	snapshot := func() {
+		today, _ := utils.ParseDate(time.Now().Format(utils.DateLayout))
+		taken, err := netWorth.SnapshotAll(today)
+		if err != nil {
+			log.Printf("净资产自动快照失败: %v", err)
+		}
+		if taken > 0 {
+			log.Printf("净资产自动快照: 生成 %d 个用户的快照", taken)
+		}
+	}
+	go func() {
+		snapshot()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			snapshot()
+		}
+	}()
+}
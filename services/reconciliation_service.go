@@ -0,0 +1,470 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrReconciliationNotFound 对账不存在或当前用户无权访问
+	ErrReconciliationNotFound = errors.New("对账记录不存在")
+	// ErrReconciliationOpen 科目已有进行中的对账
+	ErrReconciliationOpen = errors.New("该科目已有进行中的对账，请先完成或取消")
+	// ErrReconciliationFinished 对账已完成，不能再勾选账单或取消
+	ErrReconciliationFinished = errors.New("对账已完成，不能修改")
+	// ErrReconciliationUnbalanced 已核对余额与对账单余额不一致，不能完成对账
+	ErrReconciliationUnbalanced = errors.New("已核对余额与对账单余额不一致，不能完成对账")
+)
+
+type ReconciliationService struct{}
+
+// StartReconciliation 为资产或负债科目开始一次对账
+// 科目有已完成的对账时，期初余额和币种沿用上一次的对账单，对账单日期必须晚于上一次；首次对账使用 rec.OpeningBalance
+func (s *ReconciliationService) StartReconciliation(userID uint, rec *models.Reconciliation) error {
+	var account models.Account
+	if err := config.DB.Where("id = ? AND ledger_id IN (?)", rec.AccountID, memberLedgerIDs(userID)).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccountNotFound
+		}
+		return err
+	}
+	if _, err := writableLedger(account.LedgerID, userID); err != nil {
+		return err
+	}
+	if account.Type != models.AccountTypeAsset && account.Type != models.AccountTypeLiability {
+		return utils.FieldErrors{"account_id": "只能对资产或负债科目对账"}
+	}
+	currency, err := checkCurrency(rec.Currency)
+	if err != nil {
+		return err
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁住科目，避免同一科目并发开始两次对账
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Account{}, account.ID).Error; err != nil {
+			return err
+		}
+		var open int64
+		if err := tx.Model(&models.Reconciliation{}).
+			Where("account_id = ? AND status = ?", account.ID, models.ReconciliationStatusOpen).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrReconciliationOpen
+		}
+
+		var last models.Reconciliation
+		err := tx.Where("account_id = ? AND status = ?", account.ID, models.ReconciliationStatusFinished).
+			Order("statement_date DESC, id DESC").First(&last).Error
+		switch {
+		case err == nil:
+			fields := utils.FieldErrors{}
+			if !rec.StatementDate.After(last.StatementDate) {
+				fields["statement_date"] = "必须晚于上一次对账的日期 " + last.StatementDate.Format(utils.DateLayout)
+			}
+			if currency != "" && currency != last.Currency {
+				fields["currency"] = "必须与上一次对账的币种 " + last.Currency + " 相同"
+			}
+			if len(fields) > 0 {
+				return fields
+			}
+			currency = last.Currency
+			rec.OpeningBalance = last.StatementBalance
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if currency == "" {
+				if currency, err = baseCurrency(tx, userID); err != nil {
+					return err
+				}
+			}
+		default:
+			return err
+		}
+
+		rec.ID = 0
+		rec.LedgerID = account.LedgerID
+		rec.UserID = userID
+		rec.Currency = currency
+		rec.OpeningBalance = utils.FromCents(utils.ToCents(rec.OpeningBalance))
+		rec.StatementBalance = utils.FromCents(utils.ToCents(rec.StatementBalance))
+		rec.Status = models.ReconciliationStatusOpen
+		if err := tx.Create(rec).Error; err != nil {
+			return err
+		}
+		return fillReconciliation(tx, rec)
+	})
+}
+
+// ListReconciliations 查询对账记录，按对账单日期倒序
+func (s *ReconciliationService) ListReconciliations(userID uint, filter models.ReconciliationFilter) ([]models.Reconciliation, error) {
+	query := config.DB.Where("ledger_id IN (?)", memberLedgerIDs(userID))
+	if filter.LedgerID != 0 {
+		query = query.Where("ledger_id = ?", filter.LedgerID)
+	}
+	if filter.AccountID != 0 {
+		query = query.Where("account_id = ?", filter.AccountID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var recs []models.Reconciliation
+	if err := query.Order("statement_date DESC, id DESC").Find(&recs).Error; err != nil {
+		return nil, err
+	}
+	for i := range recs {
+		if err := fillReconciliation(config.DB, &recs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return recs, nil
+}
+
+// GetReconciliation 查询对账详情
+// 进行中的对账返回所有可以勾选的账单（含已勾选的），已完成的对账只返回勾选过的账单
+func (s *ReconciliationService) GetReconciliation(id, userID uint) (*models.ReconciliationDetail, error) {
+	rec, err := findReconciliation(config.DB, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fillReconciliation(config.DB, rec); err != nil {
+		return nil, err
+	}
+
+	query := config.DB.Where("reconciliation_id = ?", rec.ID)
+	if rec.Status == models.ReconciliationStatusOpen {
+		if query, err = reconcilableEntries(config.DB, rec); err != nil {
+			return nil, err
+		}
+	}
+	detail := &models.ReconciliationDetail{Reconciliation: *rec}
+	if err := query.Preload("Tags").Order("date, id").Find(&detail.Entries).Error; err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// ClearEntries 勾选（cleared 为 true）或取消勾选账单，返回更新后的对账
+// 只能勾选记入该科目、币种相同、日期不晚于对账单日期且没有在其他对账中勾选过的账单
+func (s *ReconciliationService) ClearEntries(ctx context.Context, id, userID uint, entryIDs []uint, cleared bool) (*models.Reconciliation, error) {
+	var rec *models.Reconciliation
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rec, err = openReconciliation(tx, id, userID); err != nil {
+			return err
+		}
+		ids := uniqueIDs(entryIDs)
+		if len(ids) == 0 {
+			return utils.FieldErrors{"entry_ids": "不能为空"}
+		}
+		candidates, err := reconcilableEntries(tx, rec)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := candidates.Model(&models.LedgerEntry{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(ids)) {
+			return utils.FieldErrors{"entry_ids": "包含不能在该对账中勾选的账单"}
+		}
+		if err := checkEntriesPeriodOpen(tx, ids); err != nil {
+			return err
+		}
+
+		before, err := entrySnapshots(tx, ids)
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		action := models.AuditActionEntryUnclear
+		if cleared {
+			value = rec.ID
+			action = models.AuditActionEntryClear
+		}
+		if err := tx.Model(&models.LedgerEntry{}).Where("id IN ?", ids).
+			UpdateColumn("reconciliation_id", value).Error; err != nil {
+			return err
+		}
+		if err := touchEntries(tx, ids); err != nil {
+			return err
+		}
+		if err := auditChangedEntries(tx, action, before); err != nil {
+			return err
+		}
+		return fillReconciliation(tx, rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// FinishReconciliation 完成对账：已核对余额必须等于对账单余额，勾选的账单随之锁定
+func (s *ReconciliationService) FinishReconciliation(ctx context.Context, id, userID uint) (*models.Reconciliation, error) {
+	var rec *models.Reconciliation
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rec, err = openReconciliation(tx, id, userID); err != nil {
+			return err
+		}
+		if err := fillReconciliation(tx, rec); err != nil {
+			return err
+		}
+		if utils.ToCents(rec.Difference) != 0 {
+			return ErrReconciliationUnbalanced
+		}
+
+		var ids []uint
+		if err := tx.Model(&models.LedgerEntry{}).Where("reconciliation_id = ?", rec.ID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			if err := checkEntriesPeriodOpen(tx, ids); err != nil {
+				return err
+			}
+			before, err := entrySnapshots(tx, ids)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.LedgerEntry{}).Where("id IN ?", ids).UpdateColumn("locked", true).Error; err != nil {
+				return err
+			}
+			if err := touchEntries(tx, ids); err != nil {
+				return err
+			}
+			if err := auditChangedEntries(tx, models.AuditActionEntryLock, before); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		rec.Status = models.ReconciliationStatusFinished
+		rec.FinishedAt = &now
+		return tx.Model(rec).Select("status", "finished_at").Updates(rec).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// CancelReconciliation 取消进行中的对账，已勾选的账单恢复为未核对
+func (s *ReconciliationService) CancelReconciliation(ctx context.Context, id, userID uint) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rec, err := openReconciliation(tx, id, userID)
+		if err != nil {
+			return err
+		}
+		var ids []uint
+		if err := tx.Unscoped().Model(&models.LedgerEntry{}).Where("reconciliation_id = ?", rec.ID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			if err := checkEntriesPeriodOpen(tx, ids); err != nil {
+				return err
+			}
+			before, err := entrySnapshots(tx, ids)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&models.LedgerEntry{}).Where("id IN ?", ids).
+				UpdateColumn("reconciliation_id", nil).Error; err != nil {
+				return err
+			}
+			if err := touchEntries(tx, ids); err != nil {
+				return err
+			}
+			if err := auditChangedEntries(tx, models.AuditActionEntryUnclear, before); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(rec).Error
+	})
+}
+
+// UnlockEntry 解锁已对账的账单，之后可以正常修改、删除；账单仍保留在原对账的勾选记录中
+// 解锁会写入审计日志；账单未锁定时直接返回
+func (s *ReconciliationService) UnlockEntry(ctx context.Context, id, userID uint) (*models.LedgerEntry, error) {
+	var entry *models.LedgerEntry
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current models.LedgerEntry
+		if err := visibleEntries(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID).Where("id = ?", id).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEntryNotFound
+			}
+			return err
+		}
+		if _, err := writableLedger(current.LedgerID, userID); err != nil {
+			return err
+		}
+		before, err := entrySnapshot(tx, id)
+		if err != nil {
+			return err
+		}
+		if !before.Locked {
+			entry = before
+			return nil
+		}
+		if err := checkPeriodOpen(tx, before.LedgerID, before.Date); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.LedgerEntry{}).Where("id = ?", id).UpdateColumn("locked", false).Error; err != nil {
+			return err
+		}
+		if err := touchEntries(tx, []uint{id}); err != nil {
+			return err
+		}
+		if entry, err = entrySnapshot(tx, id); err != nil {
+			return err
+		}
+		return auditEntry(tx, models.AuditActionEntryUnlock, before, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// findReconciliation 查找当前用户所在账本中的对账
+func findReconciliation(db *gorm.DB, id, userID uint) (*models.Reconciliation, error) {
+	var rec models.Reconciliation
+	if err := db.Where("id = ? AND ledger_id IN (?)", id, memberLedgerIDs(userID)).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReconciliationNotFound
+		}
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// openReconciliation 在事务中锁住进行中的对账，要求当前用户可以在账本中记账
+func openReconciliation(tx *gorm.DB, id, userID uint) (*models.Reconciliation, error) {
+	rec, err := findReconciliation(tx.Clauses(clause.Locking{Strength: "UPDATE"}), id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writableLedger(rec.LedgerID, userID); err != nil {
+		return nil, err
+	}
+	if rec.Status != models.ReconciliationStatusOpen {
+		return nil, ErrReconciliationFinished
+	}
+	return rec, nil
+}
+
+// reconcilableEntries 限定为可以在对账中勾选的账单：记入该科目（与复式记账的换算规则一致，
+// 没有填写资金账户的账单属于"现金"）、币种相同、日期不晚于对账单日期、未在其他对账中勾选
+func reconcilableEntries(db *gorm.DB, rec *models.Reconciliation) (*gorm.DB, error) {
+	var account models.Account
+	if err := db.First(&account, rec.AccountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	query := db.Where("ledger_id = ? AND currency = ? AND date < ?",
+		rec.LedgerID, rec.Currency, rec.StatementDate.AddDate(0, 0, 1).Format(utils.DateLayout)).
+		Where("reconciliation_id IS NULL OR reconciliation_id = ?", rec.ID)
+	return whereEntryAccount(query, account.Name), nil
+}
+
+// fillReconciliation 计算已勾选账单数、已核对余额和差额
+// 余额按科目的正常方向计算：资产科目收入增加、支出减少，负债科目（如信用卡）相反
+func fillReconciliation(db *gorm.DB, rec *models.Reconciliation) error {
+	var account models.Account
+	if err := db.Select("id, type").First(&account, rec.AccountID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	var row struct {
+		Count  int64
+		Amount float64
+	}
+	err := db.Model(&models.LedgerEntry{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE -amount END), 0) AS amount", models.EntryTypeIncome).
+		Where("reconciliation_id = ?", rec.ID).
+		Scan(&row).Error
+	if err != nil {
+		return err
+	}
+	cleared := utils.ToCents(row.Amount)
+	if account.Type == models.AccountTypeLiability {
+		cleared = -cleared
+	}
+	balance := utils.ToCents(rec.OpeningBalance) + cleared
+	rec.ClearedCount = row.Count
+	rec.ClearedBalance = utils.FromCents(balance)
+	rec.Difference = utils.FromCents(utils.ToCents(rec.StatementBalance) - balance)
+	return nil
+}
+
+// openReconciliations 返回 ids 中仍在进行中的对账
+func openReconciliations(db *gorm.DB, ids []uint) (map[uint]bool, error) {
+	var openIDs []uint
+	err := db.Model(&models.Reconciliation{}).
+		Where("id IN ? AND status = ?", ids, models.ReconciliationStatusOpen).
+		Pluck("id", &openIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	open := make(map[uint]bool, len(openIDs))
+	for _, id := range openIDs {
+		open[id] = true
+	}
+	return open, nil
+}
+
+// reconciledFieldsChanged 修改是否影响账单在对账中的核对结果
+func reconciledFieldsChanged(before, after *models.LedgerEntry) bool {
+	return before.Type != after.Type ||
+		utils.ToCents(before.Amount) != utils.ToCents(after.Amount) ||
+		before.Currency != after.Currency ||
+		before.Account != after.Account ||
+		before.Date.Format(utils.DateLayout) != after.Date.Format(utils.DateLayout)
+}
+
+// unclearOpenReconciliations 取消 entries 在进行中的对账里的勾选，已完成对账的勾选记录保留
+func unclearOpenReconciliations(tx *gorm.DB, entries []models.LedgerEntry) error {
+	var recIDs []uint
+	for _, entry := range entries {
+		if entry.ReconciliationID != nil {
+			recIDs = append(recIDs, *entry.ReconciliationID)
+		}
+	}
+	if len(recIDs) == 0 {
+		return nil
+	}
+	open, err := openReconciliations(tx, uniqueIDs(recIDs))
+	if err != nil {
+		return err
+	}
+	var ids []uint
+	for _, entry := range entries {
+		if entry.ReconciliationID != nil && open[*entry.ReconciliationID] {
+			ids = append(ids, entry.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return tx.Model(&models.LedgerEntry{}).Where("id IN ?", ids).UpdateColumn("reconciliation_id", nil).Error
+}
+
+// uniqueIDs 去掉重复和为 0 的 ID，保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
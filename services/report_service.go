@@ -0,0 +1,170 @@
+package services
+
+import (
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReportService struct{}
+
+// reportRow 报表查询的中间结果：某类型、分类、币种在某一天的合计
+type reportRow struct {
+	Type     int
+	Category string
+	Currency string
+	Date     time.Time
+	Amount   float64
+	Count    int64
+}
+
+// dateRange 报表日期区间，from 含当天，before 为结束日期的次日（不含）
+type dateRange struct {
+	from, before string
+}
+
+// reportEntries 限定参与统计的账单：指定账本、未删除、非借贷，并按日期范围筛选
+func reportEntries(query *gorm.DB, ledgerID uint, dates dateRange) *gorm.DB {
+	query = query.Where("ledger_entries.deleted_at IS NULL AND ledger_entries.ledger_id = ? AND ledger_entries.loan_id IS NULL", ledgerID)
+	if dates.from != "" {
+		query = query.Where("ledger_entries.date >= ?", dates.from)
+	}
+	if dates.before != "" {
+		query = query.Where("ledger_entries.date < ?", dates.before)
+	}
+	return query
+}
+
+// parseReportDates 解析报表的 start_date、end_date（均可为空，含当天）
+func parseReportDates(filter models.ReportFilter) (dateRange, error) {
+	fields := utils.FieldErrors{}
+	startDate, okStart := parseDateParam(filter.StartDate, "start_date", fields)
+	endDate, okEnd := parseDateParam(filter.EndDate, "end_date", fields)
+	if len(fields) > 0 {
+		return dateRange{}, fields
+	}
+	var dates dateRange
+	if okStart {
+		dates.from = startDate.Format(utils.DateLayout)
+	}
+	if okEnd {
+		dates.before = endDate.AddDate(0, 0, 1).Format(utils.DateLayout)
+	}
+	return dates, nil
+}
+
+// Summary 按类型和分类汇总收支，金额按每条账单日期的汇率换算为当前用户的本位币
+// 有拆分明细的账单按拆分行计入各自的分类
+// 借贷产生的资金往来（loan_id 不为空）不是真正的收入或支出，不计入统计
+func (s *ReportService) Summary(userID uint, filter models.ReportFilter) (*models.ReportSummary, error) {
+	ledger, err := resolveLedger(filter.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	dates, err := parseReportDates(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一币种同一天使用同一个汇率，按天分组后再换算
+	// 没有拆分的账单按自身分类统计，有拆分的账单按拆分行的分类统计
+	var rows, splitRows []reportRow
+	err = reportEntries(config.DB.Table("ledger_entries"), ledger.ID, dates).
+		Where("NOT EXISTS (SELECT 1 FROM entry_splits WHERE entry_splits.entry_id = ledger_entries.id)").
+		Select("ledger_entries.type, ledger_entries.category, ledger_entries.currency, ledger_entries.date, " +
+			"SUM(ledger_entries.amount) AS amount, COUNT(*) AS count").
+		Group("ledger_entries.type, ledger_entries.category, ledger_entries.currency, ledger_entries.date").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	err = reportEntries(config.DB.Table("entry_splits").
+		Joins("JOIN ledger_entries ON ledger_entries.id = entry_splits.entry_id"), ledger.ID, dates).
+		Select("ledger_entries.type, entry_splits.category, ledger_entries.currency, ledger_entries.date, " +
+			"SUM(entry_splits.amount) AS amount, COUNT(*) AS count").
+		Group("ledger_entries.type, entry_splits.category, ledger_entries.currency, ledger_entries.date").
+		Scan(&splitRows).Error
+	if err != nil {
+		return nil, err
+	}
+	rows = append(rows, splitRows...)
+
+	converter, err := newRateConverter(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	type categoryKey struct {
+		Type     int
+		Category string
+	}
+	categoryCents := make(map[categoryKey]int64)
+	categoryCounts := make(map[categoryKey]int64)
+	originals := make(map[string]*[2]int64) // 币种 -> [收入, 支出]
+	var income, expense int64
+	for _, row := range rows {
+		converted, err := converter.convert(row.Amount, row.Currency, row.Date)
+		if err != nil {
+			return nil, err
+		}
+		cents := utils.ToCents(converted)
+		key := categoryKey{row.Type, row.Category}
+		categoryCents[key] += cents
+		categoryCounts[key] += row.Count
+
+		if originals[row.Currency] == nil {
+			originals[row.Currency] = &[2]int64{}
+		}
+		switch row.Type {
+		case models.EntryTypeIncome:
+			income += cents
+			originals[row.Currency][0] += utils.ToCents(row.Amount)
+		case models.EntryTypeExpense:
+			expense += cents
+			originals[row.Currency][1] += utils.ToCents(row.Amount)
+		}
+	}
+
+	summary := &models.ReportSummary{
+		BaseCurrency: converter.base,
+		Income:       utils.FromCents(income),
+		Expense:      utils.FromCents(expense),
+		Balance:      utils.FromCents(income - expense),
+		Categories:   make([]models.CategoryTotal, 0, len(categoryCents)),
+		Currencies:   make([]models.CurrencyTotal, 0, len(originals)),
+	}
+	for key, cents := range categoryCents {
+		summary.Categories = append(summary.Categories, models.CategoryTotal{
+			Type:     key.Type,
+			Category: key.Category,
+			Amount:   utils.FromCents(cents),
+			Count:    categoryCounts[key],
+		})
+	}
+	sort.Slice(summary.Categories, func(i, j int) bool {
+		a, b := summary.Categories[i], summary.Categories[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Amount != b.Amount {
+			return a.Amount > b.Amount
+		}
+		return a.Category < b.Category
+	})
+	for currency, totals := range originals {
+		summary.Currencies = append(summary.Currencies, models.CurrencyTotal{
+			Currency: currency,
+			Income:   utils.FromCents(totals[0]),
+			Expense:  utils.FromCents(totals[1]),
+		})
+	}
+	sort.Slice(summary.Currencies, func(i, j int) bool {
+		return summary.Currencies[i].Currency < summary.Currencies[j].Currency
+	})
+	return summary, nil
+}
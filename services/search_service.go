@@ -0,0 +1,214 @@
+package services
+
+import (
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 搜索关键词限制
+const (
+	searchMaxLength = 100 // 搜索内容最多字符数
+	searchMaxTerms  = 10  // 按空白拆分后最多词数
+	ngramTokenSize  = 2   // MySQL ngram 解析器默认的 ngram_token_size，短于它的词无法用全文索引命中
+)
+
+// SearchEntries 在账单的备注和分类中搜索，按相关度从高到低排序，相关度相同时新账单在前
+// MySQL 上使用 ngram 全文索引（支持中文），其他数据库或关键词过短时退化为 LIKE：
+// 分类命中一个词计 2 分，备注命中计 1 分
+// filter 中的筛选条件同账单列表，排序参数不生效
+func (s *EntryService) SearchEntries(userID uint, q string, filter models.EntryFilter, page, pageSize int) ([]models.EntrySearchResult, int64, error) {
+	q = strings.TrimSpace(q)
+	terms := searchTerms(q)
+	fields := utils.FieldErrors{}
+	switch {
+	case q == "":
+		fields["q"] = "不能为空"
+	case utf8.RuneCountInString(q) > searchMaxLength:
+		fields["q"] = fmt.Sprintf("不能超过 %d 个字符", searchMaxLength)
+	case len(terms) > searchMaxTerms:
+		fields["q"] = fmt.Sprintf("最多 %d 个词", searchMaxTerms)
+	}
+
+	query, _, err := entryListQuery(userID, filter)
+	if filterFields, ok := err.(utils.FieldErrors); ok {
+		for k, v := range filterFields {
+			fields[k] = v
+		}
+	} else if err != nil {
+		return nil, 0, err
+	}
+	if len(fields) > 0 {
+		return nil, 0, fields
+	}
+
+	// 1. 命中条件和相关度表达式
+	var score string
+	var scoreArgs []interface{}
+	if useFullText(terms) {
+		score = "MATCH(remark, category) AGAINST (? IN NATURAL LANGUAGE MODE)"
+		scoreArgs = []interface{}{q}
+		query = query.Where(score, q)
+	} else {
+		conds := make([]string, 0, len(terms))
+		scores := make([]string, 0, len(terms))
+		var condArgs []interface{}
+		for _, term := range terms {
+			pattern := "%" + escapeLike(term) + "%"
+			conds = append(conds, "remark LIKE ? ESCAPE '!' OR category LIKE ? ESCAPE '!'")
+			condArgs = append(condArgs, pattern, pattern)
+			scores = append(scores, "(CASE WHEN category LIKE ? ESCAPE '!' THEN 2 ELSE 0 END) + (CASE WHEN remark LIKE ? ESCAPE '!' THEN 1 ELSE 0 END)")
+			scoreArgs = append(scoreArgs, pattern, pattern)
+		}
+		query = query.Where("("+strings.Join(conds, " OR ")+")", condArgs...)
+		score = "(" + strings.Join(scores, " + ") + ")"
+	}
+
+	// 2. 统计总数
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 3. 按相关度分页取出账单 ID
+	var hits []struct {
+		ID    uint
+		Score float64
+	}
+	err = query.Select("id, "+score+" AS score", scoreArgs...).
+		Order("score DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(hits) == 0 {
+		return []models.EntrySearchResult{}, total, nil
+	}
+
+	// 4. 加载账单详情，按相关度顺序组装结果并生成高亮
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	var entries []models.LedgerEntry
+	if err := config.DB.Preload("Tags").Preload("Splits").Where("id IN ?", ids).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uint]models.LedgerEntry, len(entries))
+	for _, entry := range entries {
+		byID[entry.ID] = entry
+	}
+
+	results := make([]models.EntrySearchResult, 0, len(hits))
+	for _, hit := range hits {
+		entry, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		highlights := map[string]string{}
+		if text, ok := highlight(entry.Remark, terms); ok {
+			highlights["remark"] = text
+		}
+		if text, ok := highlight(entry.Category, terms); ok {
+			highlights["category"] = text
+		}
+		results = append(results, models.EntrySearchResult{LedgerEntry: entry, Score: hit.Score, Highlights: highlights})
+	}
+	return results, total, nil
+}
+
+// searchTerms 按空白拆分搜索内容并去重，忽略大小写
+func searchTerms(q string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, term := range strings.Fields(q) {
+		key := strings.ToLower(term)
+		if !seen[key] {
+			seen[key] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// useFullText 判断能否使用全文索引：数据库支持，且每个词都不短于 ngram 长度
+func useFullText(terms []string) bool {
+	if !config.FullTextSearch {
+		return false
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < ngramTokenSize {
+			return false
+		}
+	}
+	return true
+}
+
+// highlight 用 <em> 标出文本中命中的词，其余部分做 HTML 转义；没有命中时返回 false
+// 含汉字的词还会按 ngram 拆成相邻两字标出，与全文索引的分词方式一致
+// 例如搜索 "客户晚餐" 时，"和客户吃晚餐" 中的 "客户" 和 "晚餐" 都会被标出
+func highlight(text string, terms []string) (string, bool) {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// 个别字符转小写后长度变化，退化为区分大小写匹配
+		lower = runes
+	}
+	marked := make([]bool, len(runes))
+	found := false
+	mark := func(word []rune) {
+		for i := 0; i+len(word) <= len(lower); i++ {
+			if string(lower[i:i+len(word)]) == string(word) {
+				for j := i; j < i+len(word); j++ {
+					marked[j] = true
+				}
+				found = true
+			}
+		}
+	}
+	for _, term := range terms {
+		word := []rune(strings.ToLower(term))
+		mark(word)
+		if len(word) > ngramTokenSize && containsHan(word) {
+			for i := 0; i+ngramTokenSize <= len(word); i++ {
+				mark(word[i : i+ngramTokenSize])
+			}
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+		segment := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<em>" + segment + "</em>")
+		} else {
+			b.WriteString(segment)
+		}
+		i = j
+	}
+	return b.String(), true
+}
+
+// containsHan 判断是否包含汉字
+func containsHan(word []rune) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
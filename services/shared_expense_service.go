@@ -0,0 +1,557 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"math"
+	"math/bits"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrSharedExpenseNotFound 分摊记录不存在或无权访问
+var ErrSharedExpenseNotFound = errors.New("分摊记录不存在或无权访问")
+
+// SplitParticipant 参与分摊的成员及其分摊参数
+// Value 含义随分摊方式变化：exact 为金额，percent 为百分比，shares 为份数，equal 忽略
+type SplitParticipant struct {
+	UserID uint
+	Value  float64
+}
+
+type SharedExpenseService struct{}
+
+// CreateSharedExpense 创建分摊支出，并为每个参与人生成一条支出账单
+func (s *SharedExpenseService) CreateSharedExpense(ctx context.Context, userID uint, expense *models.SharedExpense, participants []SplitParticipant) error {
+	if _, err := writableLedger(expense.LedgerID, userID); err != nil {
+		return err
+	}
+
+	// 1. 校验付款人和参与人都是账本成员
+	memberIDs, err := ledgerMembers(expense.LedgerID)
+	if err != nil {
+		return err
+	}
+	if !memberIDs[expense.PayerID] {
+		return utils.FieldErrors{"payer_id": "付款人必须是账本成员"}
+	}
+	seen := make(map[uint]bool)
+	for _, p := range participants {
+		if !memberIDs[p.UserID] {
+			return utils.FieldErrors{"participants": fmt.Sprintf("用户 %d 不是账本成员", p.UserID)}
+		}
+		if seen[p.UserID] {
+			return utils.FieldErrors{"participants": fmt.Sprintf("用户 %d 重复", p.UserID)}
+		}
+		seen[p.UserID] = true
+	}
+
+	// 2. 确定币种（默认为创建人的本位币），计算每人分摊额
+	if expense.Currency, err = checkCurrency(expense.Currency); err != nil {
+		return err
+	}
+	if expense.Currency == "" {
+		if expense.Currency, err = baseCurrency(config.DB, userID); err != nil {
+			return err
+		}
+	}
+	amounts, err := splitAmount(utils.ToCents(expense.Amount), expense.SplitMethod, participants)
+	if err != nil {
+		return err
+	}
+
+	// 3. 确定每个参与人的账单记入哪个账本
+	entryLedgers := make([]uint, len(participants))
+	for i, p := range participants {
+		if entryLedgers[i], err = entryLedgerFor(p.UserID); err != nil {
+			return err
+		}
+	}
+
+	expense.CreatedBy = userID
+	remark := strings.TrimSpace("[分摊] " + expense.Remark)
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Shares").Create(expense).Error; err != nil {
+			return err
+		}
+		for i, p := range participants {
+			entry := models.LedgerEntry{
+				UserID:   p.UserID,
+				LedgerID: entryLedgers[i],
+				Type:     models.EntryTypeExpense,
+				Amount:   utils.FromCents(amounts[i]),
+				Currency: expense.Currency,
+				Category: expense.Category,
+				Date:     expense.Date,
+				Remark:   remark,
+			}
+			if err := createEntry(tx, &entry); err != nil {
+				return err
+			}
+			share := models.SharedExpenseShare{
+				SharedExpenseID: expense.ID,
+				UserID:          p.UserID,
+				Amount:          entry.Amount,
+				EntryID:         entry.ID,
+			}
+			if err := tx.Create(&share).Error; err != nil {
+				return err
+			}
+			expense.Shares = append(expense.Shares, share)
+		}
+		return nil
+	})
+}
+
+// ListSharedExpenses 分页查询账本中的分摊支出
+func (s *SharedExpenseService) ListSharedExpenses(ledgerID, userID uint, page, pageSize int) ([]models.SharedExpense, int64, error) {
+	if _, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleViewer); err != nil {
+		return nil, 0, err
+	}
+
+	var expenses []models.SharedExpense
+	var total int64
+	query := config.DB.Model(&models.SharedExpense{}).Where("ledger_id = ?", ledgerID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("Shares").
+		Order("date desc, id desc").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&expenses).Error
+	return expenses, total, err
+}
+
+// DeleteSharedExpense 删除分摊支出及参与人对应的账单
+// 创建人、付款人或账本所有者可以删除
+func (s *SharedExpenseService) DeleteSharedExpense(ctx context.Context, id, userID uint) error {
+	var expense models.SharedExpense
+	if err := config.DB.Where("id = ? AND ledger_id IN (?)", id, memberLedgerIDs(userID)).
+		Preload("Shares").First(&expense).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSharedExpenseNotFound
+		}
+		return err
+	}
+	if expense.CreatedBy != userID && expense.PayerID != userID {
+		if _, err := checkLedgerRole(expense.LedgerID, userID, models.LedgerRoleOwner); err != nil {
+			return err
+		}
+	}
+
+	var files []string
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		entryIDs := make([]uint, 0, len(expense.Shares))
+		for _, share := range expense.Shares {
+			entryIDs = append(entryIDs, share.EntryID)
+		}
+		// 参与人的账单可能在各自的账本中，每个账本都必须仍可由账单所属的参与人写入（未归档、仍是编辑者）
+		// 结账日期由 deleteEntries 按账本逐一校验
+		var entries []models.LedgerEntry
+		if err := tx.Unscoped().Select("id", "user_id", "ledger_id").Where("id IN ?", entryIDs).Find(&entries).Error; err != nil {
+			return err
+		}
+		checked := make(map[[2]uint]bool)
+		for _, entry := range entries {
+			key := [2]uint{entry.LedgerID, entry.UserID}
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+			if _, err := writableLedger(entry.LedgerID, entry.UserID); err != nil {
+				return fmt.Errorf("参与人 %d 的账单所在账本不可写入：%w", entry.UserID, err)
+			}
+		}
+		if _, err := deleteEntries(tx, entryIDs); err != nil {
+			return err
+		}
+		var err error
+		if files, err = deleteEntryAttachments(tx, entryIDs); err != nil {
+			return err
+		}
+		return tx.Delete(&expense).Error
+	})
+	if err != nil {
+		return err
+	}
+	removeFiles(files)
+	return nil
+}
+
+// CreateSettlement 记录一笔还款：当前用户向 settlement.ToUserID 还款，币种不填时使用还款人的本位币
+func (s *SharedExpenseService) CreateSettlement(userID uint, settlement *models.Settlement) error {
+	ledger, err := checkLedgerRole(settlement.LedgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return err
+	}
+	if ledger.ArchivedAt != nil {
+		return ErrLedgerArchived
+	}
+	memberIDs, err := ledgerMembers(settlement.LedgerID)
+	if err != nil {
+		return err
+	}
+	if !memberIDs[settlement.ToUserID] || settlement.ToUserID == userID {
+		return utils.FieldErrors{"to_user_id": "收款人必须是账本中的其他成员"}
+	}
+	if utils.ToCents(settlement.Amount) <= 0 {
+		return utils.FieldErrors{"amount": "金额必须大于 0"}
+	}
+	if settlement.Currency, err = checkCurrency(settlement.Currency); err != nil {
+		return err
+	}
+	if settlement.Currency == "" {
+		if settlement.Currency, err = baseCurrency(config.DB, userID); err != nil {
+			return err
+		}
+	}
+
+	settlement.FromUserID = userID
+	return config.DB.Create(settlement).Error
+}
+
+// Balances 按币种分别计算账本中每个成员的净余额，以及结清所需的最少转账
+// 不同币种的欠款互不抵消，也不做换算，各币种分别生成转账方案
+func (s *SharedExpenseService) Balances(ledgerID, userID uint) ([]models.MemberBalance, []models.Transfer, error) {
+	if _, err := checkLedgerRole(ledgerID, userID, models.LedgerRoleViewer); err != nil {
+		return nil, nil, err
+	}
+
+	// 1. 按币种汇总付款、分摊和还款（单位：分）
+	balances := make(map[string]map[uint]int64)
+	add := func(currency string, id uint, cents int64) {
+		if balances[currency] == nil {
+			balances[currency] = make(map[uint]int64)
+		}
+		balances[currency][id] += cents
+	}
+	var expenses []models.SharedExpense
+	if err := config.DB.Where("ledger_id = ?", ledgerID).Preload("Shares").Find(&expenses).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, e := range expenses {
+		add(e.Currency, e.PayerID, utils.ToCents(e.Amount))
+		for _, share := range e.Shares {
+			add(e.Currency, share.UserID, -utils.ToCents(share.Amount))
+		}
+	}
+
+	var settlements []models.Settlement
+	if err := config.DB.Where("ledger_id = ?", ledgerID).Find(&settlements).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, st := range settlements {
+		add(st.Currency, st.FromUserID, utils.ToCents(st.Amount))
+		add(st.Currency, st.ToUserID, -utils.ToCents(st.Amount))
+	}
+
+	// 每个币种都列出全部当前成员，余额为 0 的也列出
+	memberIDs, err := ledgerMembers(ledgerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	currencies := make([]string, 0, len(balances))
+	for currency, byUser := range balances {
+		currencies = append(currencies, currency)
+		for id := range memberIDs {
+			if _, ok := byUser[id]; !ok {
+				byUser[id] = 0
+			}
+		}
+	}
+	sort.Strings(currencies)
+
+	// 2. 查询用户名（包含已退出账本但仍有欠款的成员）
+	var ids []uint
+	for _, byUser := range balances {
+		for id := range byUser {
+			ids = append(ids, id)
+		}
+	}
+	var users []models.User
+	if err := config.DB.Where("id IN ?", uniqueIDs(ids)).Find(&users).Error; err != nil {
+		return nil, nil, err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+
+	result := make([]models.MemberBalance, 0, len(ids))
+	transfers := make([]models.Transfer, 0)
+	for _, currency := range currencies {
+		byUser := balances[currency]
+		userIDs := make([]uint, 0, len(byUser))
+		for id := range byUser {
+			userIDs = append(userIDs, id)
+		}
+		sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+		for _, id := range userIDs {
+			result = append(result, models.MemberBalance{
+				UserID:   id,
+				Username: names[id],
+				Currency: currency,
+				Balance:  utils.FromCents(byUser[id]),
+			})
+		}
+		for _, transfer := range settleUp(byUser) {
+			transfer.FromUsername = names[transfer.FromUserID]
+			transfer.ToUsername = names[transfer.ToUserID]
+			transfer.Currency = currency
+			transfers = append(transfers, transfer)
+		}
+	}
+	return result, transfers, nil
+}
+
+// ledgerMembers 返回账本全部成员 ID
+func ledgerMembers(ledgerID uint) (map[uint]bool, error) {
+	var ids []uint
+	if err := config.DB.Model(&models.LedgerMember{}).Where("ledger_id = ?", ledgerID).Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	set := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// entryLedgerFor 确定为某个用户自动生成账单时记入的账本：
+// 优先使用其当前账本，当前账本不可写入时使用其默认账本
+func entryLedgerFor(userID uint) (uint, error) {
+	ledger, err := writableLedger(0, userID)
+	if err == nil {
+		return ledger.ID, nil
+	}
+	if !errors.Is(err, ErrLedgerNotFound) && !errors.Is(err, ErrLedgerForbidden) && !errors.Is(err, ErrLedgerArchived) {
+		return 0, err
+	}
+
+	var fallback models.Ledger
+	if err := config.DB.Where("owner_id = ? AND archived_at IS NULL", userID).Order("id").First(&fallback).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, utils.FieldErrors{"participants": fmt.Sprintf("用户 %d 没有可写入的账本", userID)}
+		}
+		return 0, err
+	}
+	return fallback.ID, nil
+}
+
+// splitAmount 按分摊方式把总金额（分）分配给参与人，保证各份之和等于总额
+func splitAmount(total int64, method string, participants []SplitParticipant) ([]int64, error) {
+	if total <= 0 {
+		return nil, utils.FieldErrors{"amount": "金额必须大于 0"}
+	}
+	if len(participants) == 0 {
+		return nil, utils.FieldErrors{"participants": "至少需要一个参与人"}
+	}
+
+	weights := make([]float64, len(participants))
+	switch method {
+	case models.SplitMethodEqual:
+		for i := range weights {
+			weights[i] = 1
+		}
+	case models.SplitMethodExact:
+		amounts := make([]int64, len(participants))
+		var sum int64
+		for i, p := range participants {
+			amounts[i] = utils.ToCents(p.Value)
+			if amounts[i] < 0 {
+				return nil, utils.FieldErrors{"participants": "分摊金额不能为负数"}
+			}
+			sum += amounts[i]
+		}
+		if sum != total {
+			return nil, utils.FieldErrors{"participants": fmt.Sprintf("各参与人金额之和 %.2f 不等于总金额 %.2f", utils.FromCents(sum), utils.FromCents(total))}
+		}
+		return amounts, nil
+	case models.SplitMethodPercent:
+		var sum float64
+		for i, p := range participants {
+			if p.Value < 0 {
+				return nil, utils.FieldErrors{"participants": "百分比不能为负数"}
+			}
+			weights[i] = p.Value
+			sum += p.Value
+		}
+		if math.Abs(sum-100) > 0.0001 {
+			return nil, utils.FieldErrors{"participants": "百分比之和必须等于 100"}
+		}
+	case models.SplitMethodShares:
+		for i, p := range participants {
+			if p.Value <= 0 {
+				return nil, utils.FieldErrors{"participants": "份数必须大于 0"}
+			}
+			weights[i] = p.Value
+		}
+	default:
+		return nil, utils.FieldErrors{"split_method": "分摊方式只能是 equal、exact、percent 或 shares"}
+	}
+	return allocate(total, weights), nil
+}
+
+// allocate 按权重分配整数金额（最大余数法），余下的分按小数部分从大到小补齐，小数部分相同时按顺序
+func allocate(total int64, weights []float64) []int64 {
+	var sumW float64
+	for _, w := range weights {
+		sumW += w
+	}
+
+	amounts := make([]int64, len(weights))
+	remainders := make([]float64, len(weights))
+	var allocated int64
+	for i, w := range weights {
+		exact := float64(total) * w / sumW
+		amounts[i] = int64(math.Floor(exact))
+		remainders[i] = exact - float64(amounts[i])
+		allocated += amounts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for i := 0; allocated < total; i++ {
+		amounts[order[i%len(order)]]++
+		allocated++
+	}
+	return amounts
+}
+
+// settleUpExactMaxParties 余额不为 0 的人数不超过该值时搜索最少转账，否则直接按贪心生成
+const settleUpExactMaxParties = 16
+
+// settleParty 结清方案中的一方，amount 为净余额（分），正数为应收、负数为欠款
+type settleParty struct {
+	id     uint
+	amount int64
+}
+
+// settleUp 根据净余额生成最少笔数的转账方案
+// n 个人最多可以分成 k 组互不相欠（组内余额之和为 0）时，最少需要 n-k 笔转账：先找出分组最多的划分，再在每组内结清
+// 人数超过 settleUpExactMaxParties 时不再搜索，整体按贪心结清，转账笔数不超过 n-1
+func settleUp(balances map[uint]int64) []models.Transfer {
+	parties := make([]settleParty, 0, len(balances))
+	for id, b := range balances {
+		if b != 0 {
+			parties = append(parties, settleParty{id, b})
+		}
+	}
+	sort.Slice(parties, func(i, j int) bool { return parties[i].id < parties[j].id })
+
+	groups := [][]settleParty{parties}
+	if len(parties) <= settleUpExactMaxParties {
+		groups = zeroSumGroups(parties)
+	}
+	var transfers []models.Transfer
+	for _, group := range groups {
+		transfers = append(transfers, settleGroup(group)...)
+	}
+	return transfers
+}
+
+// zeroSumGroups 把余额之和为 0 的各方划分为尽可能多的组，每组余额之和都为 0
+// dp[mask] 为 mask 中的人按某种顺序排列时，前缀和回到 0 的最多次数，即 mask 最多能分成的组数
+func zeroSumGroups(parties []settleParty) [][]settleParty {
+	n := len(parties)
+	if n == 0 {
+		return nil
+	}
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	dp := make([]int, full+1)
+	last := make([]int, full+1) // 达到 dp[mask] 时排在最后的人
+	for mask := 1; mask <= full; mask++ {
+		sums[mask] = sums[mask&(mask-1)] + parties[bits.TrailingZeros(uint(mask))].amount
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			prev := mask ^ 1<<i
+			if dp[prev] >= dp[mask] {
+				dp[mask] = dp[prev]
+				last[mask] = i
+			}
+		}
+		if sums[mask] == 0 {
+			dp[mask]++
+		}
+	}
+
+	// 从全集倒推出排列顺序，前缀和每回到一次 0 就结束一组
+	order := make([]int, 0, n)
+	for mask := full; mask != 0; mask ^= 1 << last[mask] {
+		order = append(order, last[mask])
+	}
+	var groups [][]settleParty
+	var group []settleParty
+	var sum int64
+	for k := n - 1; k >= 0; k-- {
+		group = append(group, parties[order[k]])
+		sum += parties[order[k]].amount
+		if sum == 0 {
+			groups = append(groups, group)
+			group = nil
+		}
+	}
+	// 余额之和不为 0 时（不应出现）剩下的人单独成组，按贪心结清
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// settleGroup 在一组内生成转账：每次让欠款最多的人向应收最多的人转账
+// 每笔转账至少结清一方，转账笔数不超过组内人数减一
+func settleGroup(parties []settleParty) []models.Transfer {
+	var creditors, debtors []settleParty
+	for _, p := range parties {
+		if p.amount > 0 {
+			creditors = append(creditors, p)
+		} else if p.amount < 0 {
+			debtors = append(debtors, settleParty{p.id, -p.amount})
+		}
+	}
+	byAmount := func(list []settleParty) {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].amount != list[j].amount {
+				return list[i].amount > list[j].amount
+			}
+			return list[i].id < list[j].id
+		})
+	}
+
+	var transfers []models.Transfer
+	for len(creditors) > 0 && len(debtors) > 0 {
+		byAmount(creditors)
+		byAmount(debtors)
+		amount := creditors[0].amount
+		if debtors[0].amount < amount {
+			amount = debtors[0].amount
+		}
+		transfers = append(transfers, models.Transfer{
+			FromUserID: debtors[0].id,
+			ToUserID:   creditors[0].id,
+			Amount:     utils.FromCents(amount),
+		})
+		creditors[0].amount -= amount
+		debtors[0].amount -= amount
+		if creditors[0].amount == 0 {
+			creditors = creditors[1:]
+		}
+		if debtors[0].amount == 0 {
+			debtors = debtors[1:]
+		}
+	}
+	return transfers
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrSyncTokenExpired 同步位置之后有账单被彻底删除，增量同步会漏掉删除，客户端需要清空本地数据后全量同步
+var ErrSyncTokenExpired = errors.New("同步位置已过期，请清空本地数据后重新全量同步")
+
+// ErrSyncClientIDConflict 新建账单时 client_id 被并发推送的同一账单占用，重新推送即可按已存在的账单处理
+var ErrSyncClientIDConflict = errors.New("client_id 冲突，请重新推送")
+
+// 冲突原因
+const (
+	syncReasonChanged = "server_changed" // 服务端在 base_seq 之后修改过该账单
+	syncReasonDeleted = "server_deleted" // 服务端已删除该账单
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type SyncService struct{}
+
+// syncToken 同步位置：账本和已经拉取到的最后一条变更 (sync_seq, id)
+// 编码为 base64 JSON 交给客户端，客户端只需原样传回
+type syncToken struct {
+	LedgerID uint   `json:"l"`
+	Seq      uint64 `json:"s"`
+	ID       uint   `json:"i"`
+}
+
+func (t syncToken) encode() string {
+	// 结构体只含基本类型，序列化不会失败
+	data, err := json.Marshal(t)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseSyncToken(value string) (syncToken, error) {
+	var token syncToken
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return token, utils.FieldErrors{"token": "无效的同步令牌"}
+	}
+	if err := json.Unmarshal(data, &token); err != nil || token.LedgerID == 0 {
+		return token, utils.FieldErrors{"token": "无效的同步令牌"}
+	}
+	return token, nil
+}
+
+// Pull 返回账本中同步令牌之后的账单变更（新增、修改、删除），按变更顺序排列
+// token 为空表示从头同步，返回账本中的全部账单（包括回收站中的，以删除状态返回）
+// ledgerID 为 0 时使用当前账本
+func (s *SyncService) Pull(userID, ledgerID uint, token string, limit int) (*models.SyncPullResult, error) {
+	var position syncToken
+	if token != "" {
+		var err error
+		if position, err = parseSyncToken(token); err != nil {
+			return nil, err
+		}
+		if ledgerID == 0 {
+			ledgerID = position.LedgerID
+		}
+		if position.LedgerID != ledgerID {
+			return nil, utils.FieldErrors{"token": "同步令牌不属于该账本"}
+		}
+	}
+	ledger, err := resolveLedger(ledgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	position.LedgerID = ledger.ID
+	if token != "" && position.Seq < ledger.PurgedSeq {
+		return nil, ErrSyncTokenExpired
+	}
+
+	var entries []models.LedgerEntry
+	err = config.DB.Unscoped().
+		Preload("Tags").
+		Preload("Splits").
+		Where("ledger_id = ?", ledger.ID).
+		Where("sync_seq > ? OR (sync_seq = ? AND id > ?)", position.Seq, position.Seq, position.ID).
+		Order("sync_seq, id").
+		Limit(limit + 1).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.SyncPullResult{LedgerID: ledger.ID, Changes: make([]models.SyncChange, 0, len(entries))}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		result.HasMore = true
+	}
+	for i := range entries {
+		entry := &entries[i]
+		result.Changes = append(result.Changes, syncChange(entry))
+		position.Seq, position.ID = entry.SyncSeq, entry.ID
+	}
+	result.NextToken = position.encode()
+	return result, nil
+}
+
+// Push 应用客户端的离线变更，每条变更独立生效，返回每条的处理结果
+// 冲突规则（确定性，服务端优先）：账单当前的 sync_seq 与客户端的 base_seq 不一致，
+// 说明客户端离线期间服务端已有变更，此时不应用客户端的修改或删除，返回冲突和服务端版本；
+// 已删除的账单不能再修改；客户端新建的账单以 client_id 去重，重复推送同一个 client_id 不会重复创建
+func (s *SyncService) Push(ctx context.Context, userID, ledgerID uint, changes []models.SyncPushChange) ([]models.SyncPushResult, error) {
+	ledger, err := writableLedger(ledgerID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.SyncPushResult, 0, len(changes))
+	for i, change := range changes {
+		var result models.SyncPushResult
+		if change.Err != nil {
+			result = models.SyncPushResult{Status: models.SyncStatusError, Err: change.Err}
+		} else {
+			err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				var err error
+				result, err = applySyncChange(tx, ledger.ID, userID, change)
+				return err
+			})
+			if err != nil {
+				result = models.SyncPushResult{Status: models.SyncStatusError, Err: err}
+			}
+		}
+		result.Index = i
+		if result.ClientID == nil && change.ClientID != "" {
+			clientID := change.ClientID
+			result.ClientID = &clientID
+		}
+		if result.ID == 0 {
+			result.ID = change.ID
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// applySyncChange 在事务中应用一条变更
+func applySyncChange(tx *gorm.DB, ledgerID, userID uint, change models.SyncPushChange) (models.SyncPushResult, error) {
+	var result models.SyncPushResult
+	change.ClientID = strings.ToLower(strings.TrimSpace(change.ClientID))
+	if change.ID == 0 && change.ClientID == "" {
+		return result, utils.FieldErrors{"client_id": "id 和 client_id 至少提供一个"}
+	}
+	if change.ClientID != "" && !uuidPattern.MatchString(change.ClientID) {
+		return result, utils.FieldErrors{"client_id": "必须是 UUID"}
+	}
+	if !change.Deleted && change.Entry == nil {
+		return result, utils.FieldErrors{"entry": "不能为空"}
+	}
+
+	// 锁住账单行，保证比较 base_seq 和写入之间不会插入其他修改；并发新建同一 client_id 由 (ledger_id, client_id) 唯一索引拦截
+	// client_id 只在账本内唯一，其他账本中的同名 client_id 互不影响
+	query := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"})
+	if change.ID != 0 {
+		query = query.Where("id = ?", change.ID)
+	} else {
+		query = query.Where("ledger_id = ? AND client_id = ?", ledgerID, change.ClientID)
+	}
+	var current models.LedgerEntry
+	err := query.First(&current).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return result, err
+	}
+	found := err == nil
+	if found && current.LedgerID != ledgerID {
+		return result, ErrEntryNotFound
+	}
+
+	// 1. 服务端没有该账单：新建（删除则视为已完成）
+	if !found {
+		if change.ID != 0 {
+			return result, ErrEntryNotFound
+		}
+		if change.Deleted {
+			result.Status = models.SyncStatusDeleted
+			result.Deleted = true
+			return result, nil
+		}
+		entry := change.Entry
+		entry.ID = 0
+		entry.UserID = userID
+		entry.LedgerID = ledgerID
+		entry.ClientID = &change.ClientID
+		if err := addEntry(tx, entry); err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return result, ErrSyncClientIDConflict
+			}
+			return result, err
+		}
+		return syncResult(models.SyncStatusCreated, "", entry), nil
+	}
+
+	// 2. 冲突检测：服务端版本与客户端的基准版本不一致时，保留服务端版本
+	deleted := current.DeletedAt.Valid
+	if current.SyncSeq != change.BaseSeq || (deleted && !change.Deleted) {
+		if deleted && change.Deleted {
+			return syncResult(models.SyncStatusDeleted, "", &current), nil
+		}
+		reason := syncReasonChanged
+		if deleted {
+			reason = syncReasonDeleted
+		}
+		if err := tx.Unscoped().Preload("Tags").Preload("Splits").First(&current, current.ID).Error; err != nil {
+			return result, err
+		}
+		return syncResult(models.SyncStatusConflict, reason, &current), nil
+	}
+
+	// 3. 没有冲突：应用删除或修改
+	if change.Deleted {
+		if deleted {
+			return syncResult(models.SyncStatusDeleted, "", &current), nil
+		}
+		if err := removeEntry(tx, current.ID, userID); err != nil {
+			return result, err
+		}
+		if err := tx.Unscoped().First(&current, current.ID).Error; err != nil {
+			return result, err
+		}
+		return syncResult(models.SyncStatusDeleted, "", &current), nil
+	}
+	entry, err := editEntry(tx, current.ID, userID, change.Entry)
+	if err != nil {
+		return result, err
+	}
+	return syncResult(models.SyncStatusUpdated, "", entry), nil
+}
+
+func syncResult(status, reason string, entry *models.LedgerEntry) models.SyncPushResult {
+	change := syncChange(entry)
+	return models.SyncPushResult{
+		Status:   status,
+		Reason:   reason,
+		ID:       change.ID,
+		ClientID: change.ClientID,
+		SyncSeq:  change.SyncSeq,
+		Deleted:  change.Deleted,
+		Entry:    entry,
+	}
+}
+
+func syncChange(entry *models.LedgerEntry) models.SyncChange {
+	return models.SyncChange{
+		ID:       entry.ID,
+		ClientID: entry.ClientID,
+		SyncSeq:  entry.SyncSeq,
+		Deleted:  entry.DeletedAt.Valid,
+		Entry:    entry,
+	}
+}
+
+// nextSyncSeq 分配账本的下一个变更序号
+// 递增账本行会持有行锁直到事务提交，序号较小的变更总是先提交，客户端按序号增量拉取不会遗漏
+func nextSyncSeq(tx *gorm.DB, ledgerID uint) (uint64, error) {
+	if err := tx.Model(&models.Ledger{}).Where("id = ?", ledgerID).
+		UpdateColumn("sync_seq", gorm.Expr("sync_seq + 1")).Error; err != nil {
+		return 0, err
+	}
+	var seq uint64
+	err := tx.Model(&models.Ledger{}).Where("id = ?", ledgerID).Select("sync_seq").Scan(&seq).Error
+	return seq, err
+}
+
+// touchEntries 为账单分配新的变更序号，用于软删除、恢复、标签变化等不经过 createEntry/updateEntry 的写入
+// 账单可能属于不同账本（如分摊账单），按账本分别分配
+func touchEntries(tx *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var ledgerIDs []uint
+	if err := tx.Unscoped().Model(&models.LedgerEntry{}).Where("id IN ?", ids).
+		Distinct().Pluck("ledger_id", &ledgerIDs).Error; err != nil {
+		return err
+	}
+	for _, ledgerID := range ledgerIDs {
+		seq, err := nextSyncSeq(tx, ledgerID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Model(&models.LedgerEntry{}).
+			Where("ledger_id = ? AND id IN ?", ledgerID, ids).
+			UpdateColumn("sync_seq", seq).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordPurge 彻底删除账单前记录各账本被删除的最大变更序号，早于它的同步令牌随之失效
+func recordPurge(tx *gorm.DB, ids []uint) error {
+	var rows []struct {
+		LedgerID uint
+		MaxSeq   uint64
+	}
+	if err := tx.Unscoped().Model(&models.LedgerEntry{}).
+		Select("ledger_id, MAX(sync_seq) AS max_seq").
+		Where("id IN ?", ids).Group("ledger_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := tx.Model(&models.Ledger{}).
+			Where("id = ? AND purged_seq < ?", row.LedgerID, row.MaxSeq).
+			UpdateColumn("purged_seq", row.MaxSeq).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTagNotFound 标签不存在或无权访问
+	ErrTagNotFound = errors.New("标签不存在或无权访问")
+	// ErrTagExists 同一账本中已有同名标签
+	ErrTagExists = errors.New("已存在同名标签，如需合并请使用合并接口")
+	// ErrTagInUse 标签被储蓄目标关联
+	ErrTagInUse = errors.New("标签已被储蓄目标关联，请先修改或删除目标，也可以合并到其他标签")
+)
+
+// 标签规则
+const (
+	tagNameMaxRunes = 50
+	entryMaxTags    = 20
+)
+
+type TagService struct{}
+
+// ListTags 查询账本中的标签及使用次数
+func (s *TagService) ListTags(ledgerID, userID uint) ([]models.TagWithCount, error) {
+	ledger, err := resolveLedger(ledgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []models.TagWithCount
+	err = config.DB.Model(&models.Tag{}).
+		Select("tags.*, COUNT(ledger_entries.id) AS entry_count").
+		Joins("LEFT JOIN entry_tags ON entry_tags.tag_id = tags.id").
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.id = entry_tags.ledger_entry_id AND ledger_entries.deleted_at IS NULL").
+		Where("tags.ledger_id = ?", ledger.ID).
+		Group("tags.id").
+		Order("tags.name").
+		Scan(&tags).Error
+	return tags, err
+}
+
+// RenameTag 重命名标签，新名称已被占用时返回 ErrTagExists
+func (s *TagService) RenameTag(tagID, userID uint, name string) (*models.Tag, error) {
+	tag, err := writableTag(tagID, userID)
+	if err != nil {
+		return nil, err
+	}
+	names, err := normalizeTagNames([]string{name})
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.Tag{}).
+		Where("ledger_id = ? AND name = ? AND id <> ?", tag.LedgerID, names[0], tag.ID).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrTagExists
+	}
+
+	tag.Name = names[0]
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(tag).Update("name", tag.Name).Error; err != nil {
+			return err
+		}
+		return touchTaggedEntries(tx, tag.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// MergeTag 把 sourceID 合并到 targetID：原来带 source 的账单改为带 target，然后删除 source
+func (s *TagService) MergeTag(sourceID, targetID, userID uint) (*models.Tag, error) {
+	if sourceID == targetID {
+		return nil, utils.FieldErrors{"target_id": "不能合并到自身"}
+	}
+	source, err := writableTag(sourceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := writableTag(targetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if source.LedgerID != target.LedgerID {
+		return nil, utils.FieldErrors{"target_id": "只能合并同一账本中的标签"}
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := touchTaggedEntries(tx, source.ID); err != nil {
+			return err
+		}
+		// 已经同时带有两个标签的账单只保留 target，避免重复
+		err := tx.Exec(`INSERT INTO entry_tags (ledger_entry_id, tag_id)
+			SELECT ledger_entry_id, ? FROM entry_tags
+			WHERE tag_id = ? AND ledger_entry_id NOT IN (SELECT ledger_entry_id FROM entry_tags WHERE tag_id = ?)`,
+			target.ID, source.ID, target.ID).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM entry_tags WHERE tag_id = ?", source.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Goal{}).Where("tag_id = ?", source.ID).Update("tag_id", target.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(source).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// DeleteTag 删除标签，账单本身不受影响；被储蓄目标关联时返回 ErrTagInUse
+func (s *TagService) DeleteTag(tagID, userID uint) error {
+	tag, err := writableTag(tagID, userID)
+	if err != nil {
+		return err
+	}
+	var goals int64
+	if err := config.DB.Model(&models.Goal{}).Where("tag_id = ?", tag.ID).Count(&goals).Error; err != nil {
+		return err
+	}
+	if goals > 0 {
+		return ErrTagInUse
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := touchTaggedEntries(tx, tag.ID); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM entry_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(tag).Error
+	})
+}
+
+// touchTaggedEntries 标签变化时为带该标签的账单分配新的变更序号，让同步客户端拉取到新的标签
+// 标签的变化同样会修改账单，带该标签的账单有落在已结账期间内的时返回 ErrPeriodClosed
+func touchTaggedEntries(tx *gorm.DB, tagID uint) error {
+	var ids []uint
+	if err := tx.Table("entry_tags").Where("tag_id = ?", tagID).Pluck("ledger_entry_id", &ids).Error; err != nil {
+		return err
+	}
+	if err := checkEntriesPeriodOpen(tx, ids); err != nil {
+		return err
+	}
+	return touchEntries(tx, ids)
+}
+
+// writableTag 查找标签并校验当前用户可以修改其所属账本
+func writableTag(tagID, userID uint) (*models.Tag, error) {
+	var tag models.Tag
+	if err := config.DB.Where("id = ? AND ledger_id IN (?)", tagID, memberLedgerIDs(userID)).First(&tag).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		return nil, err
+	}
+	if _, err := writableLedger(tag.LedgerID, userID); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// normalizeTagNames 去除首尾空白并去重，标签名不能为空、不能包含逗号（筛选参数用逗号分隔）
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		lower := strings.ToLower(name)
+		if seen[lower] {
+			continue
+		}
+		if name == "" || strings.Contains(name, ",") || len([]rune(name)) > tagNameMaxRunes {
+			return nil, utils.FieldErrors{"tags": fmt.Sprintf("标签不能为空、不能包含逗号且不超过 %d 个字符", tagNameMaxRunes)}
+		}
+		seen[lower] = true
+		result = append(result, name)
+	}
+	if len(result) > entryMaxTags {
+		return nil, utils.FieldErrors{"tags": fmt.Sprintf("每条账单最多 %d 个标签", entryMaxTags)}
+	}
+	return result, nil
+}
+
+// resolveTags 把账单上只带名称的标签换成账本中已有的标签，不存在的自动创建
+func resolveTags(tx *gorm.DB, ledgerID uint, tags []models.Tag) ([]models.Tag, error) {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	names, err := normalizeTagNames(names)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tag := models.Tag{LedgerID: ledgerID, Name: name}
+		if err := tx.Where("ledger_id = ? AND name = ?", ledgerID, name).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, tag)
+	}
+	return resolved, nil
+}
+
+// splitTagParam 解析逗号分隔的标签筛选参数，忽略空项和重复项
+func splitTagParam(param string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// filterByTags 按标签筛选账单：any 为包含任意一个，all 为同时包含全部
+func filterByTags(query *gorm.DB, ledgerID uint, any, all []string) *gorm.DB {
+	if len(any) > 0 {
+		query = query.Where("id IN (?)", config.DB.Table("entry_tags").
+			Select("entry_tags.ledger_entry_id").
+			Joins("JOIN tags ON tags.id = entry_tags.tag_id").
+			Where("tags.ledger_id = ? AND tags.name IN ?", ledgerID, any))
+	}
+	if len(all) > 0 {
+		query = query.Where("id IN (?)", config.DB.Table("entry_tags").
+			Select("entry_tags.ledger_entry_id").
+			Joins("JOIN tags ON tags.id = entry_tags.tag_id").
+			Where("tags.ledger_id = ? AND tags.name IN ?", ledgerID, all).
+			Group("entry_tags.ledger_entry_id").
+			Having("COUNT(DISTINCT tags.id) = ?", len(all)))
+	}
+	return query
+}
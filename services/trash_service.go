@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go-ledger/config"
+	"go-ledger/models"
+	"go-ledger/utils"
+	"log"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// 回收站默认配置，可在 config.yaml 的 trash 下覆盖
+const (
+	defaultTrashRetentionDays = 30        // 账单删除后在回收站保留的天数
+	defaultTrashPurgeInterval = time.Hour // 自动清理的执行间隔
+	trashPurgeBatchSize       = 500       // 自动清理每批处理的账单数
+)
+
+type TrashService struct{}
+
+// trashEntries 用户可见账本中可以恢复的已删除账单
+// 随借贷或分摊一起删除的账单不能单独恢复，不出现在回收站中，到期后由自动清理彻底删除
+func trashEntries(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Unscoped().
+		Where("deleted_at IS NOT NULL AND loan_id IS NULL").
+		Where("ledger_id IN (?)", memberLedgerIDs(userID)).
+		Where("id NOT IN (?)", config.DB.Model(&models.SharedExpenseShare{}).Select("entry_id").
+			Where("shared_expense_id IN (?)", config.DB.Unscoped().Model(&models.SharedExpense{}).
+				Select("id").Where("deleted_at IS NOT NULL")))
+}
+
+// ListTrash 分页查询账本回收站中的账单，最近删除的在前
+// ledgerID 为 0 时使用当前账本
+func (s *TrashService) ListTrash(userID, ledgerID uint, page, pageSize int) ([]models.LedgerEntry, int64, error) {
+	ledger, err := resolveLedger(ledgerID, userID, models.LedgerRoleViewer)
+	if err != nil {
+		return nil, 0, err
+	}
+	query := trashEntries(config.DB.Model(&models.LedgerEntry{}), userID).Where("ledger_id = ?", ledger.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.LedgerEntry
+	err = query.Preload("Tags").
+		Preload("Splits").
+		Order("deleted_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// RestoreEntry 从回收站恢复账单，附件、标签和拆分明细随之恢复
+func (s *TrashService) RestoreEntry(ctx context.Context, id, userID uint) (*models.LedgerEntry, error) {
+	entry, err := writableTrashEntry(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	var restored *models.LedgerEntry
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := entrySnapshot(tx, entry.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkPeriodOpen(tx, before.LedgerID, before.Date); err != nil {
+			return err
+		}
+		result := tx.Unscoped().Model(entry).Where("deleted_at IS NOT NULL").Update("deleted_at", nil)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEntryNotFound
+		}
+		if err := touchEntries(tx, []uint{entry.ID}); err != nil {
+			return err
+		}
+		if restored, err = entrySnapshot(tx, entry.ID); err != nil {
+			return err
+		}
+		return auditEntry(tx, models.AuditActionEntryRestore, before, restored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// PurgeEntry 彻底删除回收站中的账单及其附件，不可恢复
+func (s *TrashService) PurgeEntry(ctx context.Context, id, userID uint) error {
+	entry, err := writableTrashEntry(id, userID)
+	if err != nil {
+		return err
+	}
+	var files []string
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		files, err = purgeEntries(tx, []uint{entry.ID})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	removeFiles(files)
+	return nil
+}
+
+// EmptyTrash 清空账本的回收站，返回彻底删除的账单数
+func (s *TrashService) EmptyTrash(ctx context.Context, userID, ledgerID uint) (int, error) {
+	ledger, err := resolveLedger(ledgerID, userID, models.LedgerRoleEditor)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := writableLedger(ledger.ID, userID); err != nil {
+		return 0, err
+	}
+
+	var ids []uint
+	var files []string
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := trashEntries(tx.Model(&models.LedgerEntry{}), userID).
+			Where("ledger_id = ?", ledger.ID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		var err error
+		files, err = purgeEntries(tx, ids)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	removeFiles(files)
+	return len(ids), nil
+}
+
+// PurgeExpired 彻底删除删除时间早于 before 的所有账单（包括随借贷、分摊一起删除的），返回删除数量
+// 由自动清理调用时 ctx 中没有请求信息，审计日志的操作人为 0
+func (s *TrashService) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	purged := 0
+	for {
+		var ids []uint
+		if err := config.DB.Unscoped().Model(&models.LedgerEntry{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+			Order("id").Limit(trashPurgeBatchSize).Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+
+		var files []string
+		err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			files, err = purgeEntries(tx, ids)
+			return err
+		})
+		if err != nil {
+			return purged, err
+		}
+		removeFiles(files)
+		purged += len(ids)
+	}
+}
+
+// StartTrashPurge 启动回收站自动清理：按 trash.purge_interval 定期彻底删除超过 trash.retention_days 天的账单
+// trash.auto_purge 设为 false 时不启动
+func StartTrashPurge() {
+	if viper.IsSet("trash.auto_purge") && !viper.GetBool("trash.auto_purge") {
+		return
+	}
+	retention := utils.ConfigInt("trash.retention_days", defaultTrashRetentionDays)
+	interval := utils.ConfigDuration("trash.purge_interval", defaultTrashPurgeInterval)
+	trash := new(TrashService)
+
+	purge := func() {
+		purged, err := trash.PurgeExpired(context.Background(), time.Now().AddDate(0, 0, -retention))
+		if err != nil {
+			log.Printf("回收站自动清理失败: %v", err)
+		}
+		if purged > 0 {
+			log.Printf("回收站自动清理: 彻底删除 %d 条账单", purged)
+		}
+	}
+	go func() {
+		purge()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purge()
+		}
+	}()
+}
+
+// writableTrashEntry 查找回收站中的账单，要求当前用户至少是所属账本的编辑者，且账本未归档
+func writableTrashEntry(id, userID uint) (*models.LedgerEntry, error) {
+	var entry models.LedgerEntry
+	if err := trashEntries(config.DB, userID).Where("id = ?", id).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEntryNotFound
+		}
+		return nil, err
+	}
+	if _, err := writableLedger(entry.LedgerID, userID); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// purgeEntries 在事务中彻底删除账单及其拆分明细、标签关联和附件记录，返回需要在提交后删除的文件 key
+func purgeEntries(tx *gorm.DB, ids []uint) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	before, err := entrySnapshots(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordPurge(tx, ids); err != nil {
+		return nil, err
+	}
+	files, err := deleteEntryAttachments(tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("entry_id IN ?", ids).Delete(&models.EntrySplit{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM entry_tags WHERE ledger_entry_id IN ?", ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.LedgerEntry{}).Error; err != nil {
+		return nil, err
+	}
+	if err := auditRemovedEntries(tx, models.AuditActionEntryPurge, before); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalDriver 本地文件系统存储，文件保存在 Dir 目录下
+type LocalDriver struct {
+	Dir string
+}
+
+// NewLocalDriver 创建本地存储，目录不存在时自动创建
+func NewLocalDriver(dir string) (*LocalDriver, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	return &LocalDriver{Dir: abs}, nil
+}
+
+// path 把 key 转换为本地路径，拒绝跳出存储目录的 key
+func (d *LocalDriver) path(key string) (string, error) {
+	p := filepath.Join(d.Dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, d.Dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("非法的文件 key: %s", key)
+	}
+	return p, nil
+}
+
+func (d *LocalDriver) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免读到写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (d *LocalDriver) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d *LocalDriver) Delete(ctx context.Context, key string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("文件不存在")
+
+// Driver 文件存储驱动，key 由调用方生成，使用 / 分隔的相对路径
+type Driver interface {
+	// Put 写入文件，size 为内容长度
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取文件，调用方负责关闭；文件不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// Default 全局存储驱动，由 Init 根据配置创建
+var Default Driver
+
+// Init 根据 storage.driver 配置初始化存储驱动，需在配置加载后调用
+func Init() error {
+	driver := strings.ToLower(viper.GetString("storage.driver"))
+	switch driver {
+	case "", "local":
+		dir := viper.GetString("storage.local.dir")
+		if dir == "" {
+			dir = "uploads"
+		}
+		local, err := NewLocalDriver(dir)
+		if err != nil {
+			return err
+		}
+		Default = local
+	case "s3":
+		s3, err := NewS3Driver(S3Config{
+			Endpoint:     os.ExpandEnv(viper.GetString("storage.s3.endpoint")),
+			Region:       os.ExpandEnv(viper.GetString("storage.s3.region")),
+			Bucket:       os.ExpandEnv(viper.GetString("storage.s3.bucket")),
+			AccessKey:    os.ExpandEnv(viper.GetString("storage.s3.access_key")),
+			SecretKey:    os.ExpandEnv(viper.GetString("storage.s3.secret_key")),
+			UsePathStyle: viper.GetBool("storage.s3.use_path_style"),
+		})
+		if err != nil {
+			return err
+		}
+		Default = s3
+	default:
+		return fmt.Errorf("不支持的存储驱动: %s", driver)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigInt 读取正整数配置，未配置时使用默认值
+func ConfigInt(key string, def int) int {
+	if v := viper.GetInt(key); v > 0 {
+		return v
+	}
+	return def
+}
+
+// ConfigDuration 读取时长配置（如 "15m"），未配置时使用默认值
+func ConfigDuration(key string, def time.Duration) time.Duration {
+	if v := viper.GetDuration(key); v > 0 {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+// ToCents 把元转换为分，金额计算统一用整数分进行，避免浮点误差
+func ToCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromCents 把分转换为元
+func FromCents(cents int64) float64 {
+	return float64(cents) / 100
+}
+
+// NormalizeCurrency 规范化 ISO 4217 币种代码（三位字母，统一转大写）
+// 格式不合法时第二个返回值为 false
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", false
+		}
+	}
+	return code, true
+}
@@ -1,45 +1,238 @@
 package utils
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/spf13/viper"
 )
 
-// 定义 JWT 的密钥 (生产环境请放到环境变量中，不要硬编码)
-var jwtKey = []byte(viper.GetString("jwt.secret"))
+const (
+	minSecretLength   = 32 // HS256 密钥最少字节数
+	minSecretDistinct = 8  // HS256 密钥中至少包含多少种不同字节，过滤 "aaaa..." 这类密钥
+	minRSAKeyBits     = 2048
+	defaultTokenTTL   = 24 * time.Hour
+)
+
+// jwtKey 一把签名 / 验签密钥
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{} // 签名用（HS256 为 []byte，RS256 为 *rsa.PrivateKey，EdDSA 为 ed25519.PrivateKey）
+	verifyKey interface{} // 验签用（HS256 为 []byte，其余为公钥）
+}
+
+// verifyKeyConfig 对应 config.yaml 中 jwt.verify_keys 的一项
+type verifyKeyConfig struct {
+	Kid           string `mapstructure:"kid"`
+	Algorithm     string `mapstructure:"algorithm"`
+	Secret        string `mapstructure:"secret"`
+	PublicKeyFile string `mapstructure:"public_key_file"`
+}
+
+var (
+	signingKey *jwtKey            // 当前用于签发 Token 的密钥
+	verifyKeys map[string]*jwtKey // 按 kid 索引的所有可验签密钥（含当前密钥）
+	tokenTTL   = defaultTokenTTL
+)
+
+// InitJWT 加载 JWT 密钥，必须在 config.InitConfig 之后调用
+// 当前密钥用于签发，jwt.verify_keys 中的旧密钥只用于验签，便于轮换密钥时不让已登录用户掉线
+func InitJWT() error {
+	algorithm := viper.GetString("jwt.algorithm")
+	if algorithm == "" {
+		algorithm = jwt.SigningMethodHS256.Alg()
+	}
+	kid := viper.GetString("jwt.kid")
+	if kid == "" {
+		kid = "default"
+	}
+
+	current, err := loadSigningKey(kid, algorithm)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*jwtKey{current.kid: current}
+	var extra []verifyKeyConfig
+	if err := viper.UnmarshalKey("jwt.verify_keys", &extra); err != nil {
+		return fmt.Errorf("读取 jwt.verify_keys 失败: %v", err)
+	}
+	for _, cfg := range extra {
+		if cfg.Kid == "" {
+			return errors.New("jwt.verify_keys 中的每个密钥都必须设置 kid")
+		}
+		if _, ok := keys[cfg.Kid]; ok {
+			return fmt.Errorf("jwt kid 重复: %s", cfg.Kid)
+		}
+		if cfg.Algorithm == "" {
+			cfg.Algorithm = algorithm
+		}
+		key, err := loadVerifyKey(cfg)
+		if err != nil {
+			return err
+		}
+		keys[cfg.Kid] = key
+	}
+
+	signingKey = current
+	verifyKeys = keys
+	tokenTTL = ConfigDuration("jwt.expire", defaultTokenTTL)
+	return nil
+}
+
+// loadSigningKey 按算法加载当前签名密钥
+func loadSigningKey(kid, algorithm string) (*jwtKey, error) {
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := os.ExpandEnv(viper.GetString("jwt.secret"))
+		if err := checkSecret(secret); err != nil {
+			return nil, fmt.Errorf("jwt.secret %v", err)
+		}
+		return &jwtKey{kid: kid, method: jwt.SigningMethodHS256, signKey: []byte(secret), verifyKey: []byte(secret)}, nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		path := os.ExpandEnv(viper.GetString("jwt.private_key_file"))
+		if path == "" {
+			return nil, fmt.Errorf("%s 需要配置 jwt.private_key_file", algorithm)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取私钥失败: %v", err)
+		}
+		if algorithm == jwt.SigningMethodRS256.Alg() {
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("解析 RSA 私钥失败: %v", err)
+			}
+			if priv.N.BitLen() < minRSAKeyBits {
+				return nil, fmt.Errorf("RSA 私钥长度不能少于 %d 位", minRSAKeyBits)
+			}
+			return &jwtKey{kid: kid, method: jwt.SigningMethodRS256, signKey: priv, verifyKey: &priv.PublicKey}, nil
+		}
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析 Ed25519 私钥失败: %v", err)
+		}
+		edKey, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("私钥不是 Ed25519 类型")
+		}
+		return &jwtKey{kid: kid, method: jwt.SigningMethodEdDSA, signKey: edKey, verifyKey: edKey.Public()}, nil
+	default:
+		return nil, fmt.Errorf("不支持的 jwt.algorithm: %s", algorithm)
+	}
+}
+
+// loadVerifyKey 加载只用于验签的旧密钥
+func loadVerifyKey(cfg verifyKeyConfig) (*jwtKey, error) {
+	switch cfg.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := os.ExpandEnv(cfg.Secret)
+		if err := checkSecret(secret); err != nil {
+			return nil, fmt.Errorf("jwt 验签密钥 %s %v", cfg.Kid, err)
+		}
+		return &jwtKey{kid: cfg.Kid, method: jwt.SigningMethodHS256, verifyKey: []byte(secret)}, nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+		if cfg.PublicKeyFile == "" {
+			return nil, fmt.Errorf("jwt 验签密钥 %s 需要配置 public_key_file", cfg.Kid)
+		}
+		data, err := os.ReadFile(os.ExpandEnv(cfg.PublicKeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("读取公钥 %s 失败: %v", cfg.Kid, err)
+		}
+		var pub crypto.PublicKey
+		var method jwt.SigningMethod
+		if cfg.Algorithm == jwt.SigningMethodRS256.Alg() {
+			pub, err = jwt.ParseRSAPublicKeyFromPEM(data)
+			method = jwt.SigningMethodRS256
+		} else {
+			pub, err = jwt.ParseEdPublicKeyFromPEM(data)
+			method = jwt.SigningMethodEdDSA
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析公钥 %s 失败: %v", cfg.Kid, err)
+		}
+		if rsaPub, ok := pub.(*rsa.PublicKey); ok && rsaPub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA 公钥 %s 长度不能少于 %d 位", cfg.Kid, minRSAKeyBits)
+		}
+		return &jwtKey{kid: cfg.Kid, method: method, verifyKey: pub}, nil
+	default:
+		return nil, fmt.Errorf("jwt 验签密钥 %s 使用了不支持的算法: %s", cfg.Kid, cfg.Algorithm)
+	}
+}
+
+// checkSecret 拒绝空密钥和弱密钥
+func checkSecret(secret string) error {
+	if secret == "" {
+		return errors.New("未配置")
+	}
+	if len(secret) < minSecretLength {
+		return fmt.Errorf("长度不能少于 %d 字节", minSecretLength)
+	}
+	distinct := make(map[byte]struct{})
+	for i := 0; i < len(secret); i++ {
+		distinct[secret[i]] = struct{}{}
+	}
+	if len(distinct) < minSecretDistinct {
+		return errors.New("过于简单，请使用随机生成的密钥")
+	}
+	return nil
+}
 
 // GenerateToken 生成 JWT Token
 func GenerateToken(userID uint) (string, error) {
+	if signingKey == nil {
+		return "", errors.New("JWT 密钥未初始化")
+	}
+
 	// 定义 Claims (载荷)
 	claims := jwt.MapClaims{
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // 过期时间：24小时
+		"exp":     time.Now().Add(tokenTTL).Unix(),
 	}
 
-	// 生成 Token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	// 生成 Token，kid 写入 Header，验签时据此选择密钥
+	token := jwt.NewWithClaims(signingKey.method, claims)
+	token.Header["kid"] = signingKey.kid
+	return token.SignedString(signingKey.signKey)
 }
 
 // 解析并验证token
 func ParseToken(tokenString string) (float64, error) {
+	if signingKey == nil {
+		return 0, errors.New("JWT 密钥未初始化")
+	}
+
 	// 解析token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// 确保使用的是 HMAC 签名方法
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		// 没有 kid 的旧 Token 按当前密钥校验
+		key := signingKey
+		if kid, ok := token.Header["kid"].(string); ok {
+			if key, ok = verifyKeys[kid]; !ok {
+				return nil, fmt.Errorf("unknown kid: %s", kid)
+			}
+		}
+		// 确保签名算法与该密钥一致，防止算法混淆攻击
+		if token.Method.Alg() != key.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtKey, nil
+		return key.verifyKey, nil
 	})
 	if err != nil {
 		return 0, err
 	}
 	// 验证token是否有效，并提取Claims
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := claims["user_id"].(float64)
+		userID, ok := claims["user_id"].(float64)
+		if !ok {
+			return 0, fmt.Errorf("invalid token")
+		}
 		return userID, nil
 	} else {
 		return 0, fmt.Errorf("invalid token")
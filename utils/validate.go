@@ -0,0 +1,139 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldErrors 字段级校验错误，key 为字段名（与 JSON 字段一致），value 为错误描述
+// 实现了 error 接口，Service 可以直接返回，Controller 用 errors.As 取出后原样返回给前端
+type FieldErrors map[string]string
+
+func (e FieldErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		msgs = append(msgs, k+": "+e[k])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// 用户名 / 密码规则默认值，可在 config.yaml 的 auth.username、auth.password 下覆盖
+const (
+	defaultUsernameMinLength = 3
+	defaultUsernameMaxLength = 32
+	defaultPasswordMinLength = 8
+	defaultPasswordMinClass  = 3  // 大写、小写、数字、符号四类中至少包含几类
+	passwordMaxLength        = 72 // bcrypt 只使用前 72 个字节
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+var commonPasswords = func() map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, line := range strings.Split(commonPasswordList, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			set[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return set
+}()
+
+// ValidateUsername 校验用户名长度与字符集
+// 允许字母（含中文）、数字以及 _ - .，不允许空白和控制字符
+func ValidateUsername(username string) string {
+	minLen := ConfigInt("auth.username.min_length", defaultUsernameMinLength)
+	maxLen := ConfigInt("auth.username.max_length", defaultUsernameMaxLength)
+
+	if !utf8.ValidString(username) {
+		return "用户名包含非法字符"
+	}
+	n := utf8.RuneCountInString(username)
+	if n < minLen || n > maxLen {
+		return fmt.Sprintf("用户名长度需在 %d 到 %d 个字符之间", minLen, maxLen)
+	}
+	for _, r := range username {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			continue
+		}
+		return "用户名只能包含字母、数字、下划线、短横线和点"
+	}
+	return ""
+}
+
+// ValidatePassword 按密码策略校验密码，返回空字符串表示通过
+func ValidatePassword(password, username string) string {
+	minLen := ConfigInt("auth.password.min_length", defaultPasswordMinLength)
+	minClass := ConfigInt("auth.password.min_char_classes", defaultPasswordMinClass)
+
+	if utf8.RuneCountInString(password) < minLen {
+		return fmt.Sprintf("密码长度不能少于 %d 个字符", minLen)
+	}
+	if len(password) > passwordMaxLength {
+		return fmt.Sprintf("密码长度不能超过 %d 个字节", passwordMaxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsControl(r):
+			return "密码不能包含控制字符"
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, ok := range []bool{upper, lower, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+	if classes < minClass {
+		return fmt.Sprintf("密码需至少包含大写字母、小写字母、数字、符号中的 %d 类", minClass)
+	}
+
+	lowered := strings.ToLower(password)
+	if _, ok := commonPasswords[lowered]; ok {
+		return "密码过于常见，请更换"
+	}
+	if username != "" && strings.Contains(lowered, strings.ToLower(username)) {
+		return "密码不能包含用户名"
+	}
+	return ""
+}
+
+// DateLayout 查询参数中日期的格式
+const DateLayout = "2006-01-02"
+
+// ParseDate 严格解析 YYYY-MM-DD 格式的日期参数
+func ParseDate(value string) (time.Time, bool) {
+	t, err := time.Parse(DateLayout, value)
+	return t, err == nil
+}
+
+// ParseDateTime 解析 RFC3339 时间或 YYYY-MM-DD 日期（按当天 0 点，UTC）
+// dateOnly 表示传入的是日期，作为区间上限时调用方应包含当天
+func ParseDateTime(value string) (t time.Time, dateOnly bool, ok bool) {
+	if t, ok := ParseDate(value); ok {
+		return t, true, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err == nil
+}